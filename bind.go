@@ -0,0 +1,500 @@
+package jsonmap
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BindOptions are our Decode and FromValue options
+type BindOptions struct {
+	TagName string
+}
+
+// BindOption is a bind option setter
+type BindOption func(o *BindOptions)
+
+func newBindOptions(opt ...BindOption) BindOptions {
+	opts := BindOptions{TagName: "json"}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return opts
+}
+
+// TagName sets the struct tag naming the fields, ie "yaml"
+// Fields without this tag are named by their json tag, then by their Go name
+// default : "json"
+func TagName(name string) BindOption {
+	return func(opts *BindOptions) {
+		if len(name) > 0 {
+			opts.TagName = name
+		}
+	}
+}
+
+// BindError is returned when a Go value can't be decoded from or converted to a json
+type BindError struct {
+	Path string // path of the value, empty for a root json
+	msg  string
+	err  error
+}
+
+// Error implements the error interface
+func (e *BindError) Error() string {
+	if len(e.Path) == 0 {
+		return "jsonmap: " + e.msg
+	}
+	return fmt.Sprintf("jsonmap: %s at '%s'", e.msg, e.Path)
+}
+
+// Unwrap returns the underlying error if any, ie returned by an UnmarshalJSON method
+func (e *BindError) Unwrap() error {
+	return e.err
+}
+
+// maxBindDepth limits the nesting of converted values, to detect cycles
+const maxBindDepth = 1000
+
+var (
+	jsonType          = reflect.TypeOf(Json{})
+	jsonPtrType       = reflect.TypeOf(&Json{})
+	timeType          = reflect.TypeOf(time.Time{})
+	numberType        = reflect.TypeOf(json.Number(""))
+	jsonizerType      = reflect.TypeOf((*Jsonizer)(nil)).Elem()
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	unmarshalerType   = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// pathTagName is the struct tag binding a field to a path, ie `jsonmap:"aggregations.total.value"`
+const pathTagName = "jsonmap"
+
+// bindField is a struct field bound to a json key
+type bindField struct {
+	name      string
+	index     []int     // see reflect.Value.FieldByIndex
+	path      []pathKey // keys of the path tag, nil if bound to the key name
+	omitEmpty bool
+	asString  bool // ",string" option
+}
+
+type fieldCacheKey struct {
+	t   reflect.Type
+	tag string
+}
+
+// fieldCache caches the bound fields of struct types
+var fieldCache sync.Map // map[fieldCacheKey][]bindField
+
+// structFields returns the bound fields of a struct type
+// Fields of embedded structs are promoted, the less nested field wins
+func structFields(t reflect.Type, tagName string) []bindField {
+	key := fieldCacheKey{t, tagName}
+	if fields, ok := fieldCache.Load(key); ok {
+		return fields.([]bindField)
+	}
+
+	var fields []bindField
+	depths := make(map[string]int)
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name, opts, tagged := fieldTag(sf, tagName)
+			path, hasPath := sf.Tag.Lookup(pathTagName)
+			if (name == "-" && !tagged) || path == "-" {
+				continue
+			}
+			if hasPath && len(path) > 0 {
+				name = path
+			} else {
+				hasPath = false
+			}
+
+			fieldIndex := make([]int, len(index), len(index)+1)
+			copy(fieldIndex, index)
+			fieldIndex = append(fieldIndex, i)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && len(name) == 0 && ft.Kind() == reflect.Struct {
+				if sf.Type.Kind() == reflect.Ptr && sf.PkgPath != "" {
+					continue // can't allocate an unexported embedded pointer
+				}
+				collect(ft, fieldIndex)
+				continue
+			}
+			if sf.PkgPath != "" {
+				continue // unexported
+			}
+			if len(name) == 0 {
+				name = sf.Name
+			}
+
+			depth := len(fieldIndex)
+			if d, ok := depths[name]; ok && d <= depth {
+				continue
+			}
+			depths[name] = depth
+			f := bindField{
+				name:      name,
+				index:     fieldIndex,
+				omitEmpty: strings.Contains(opts, ",omitempty"),
+				asString:  strings.Contains(opts, ",string"),
+			}
+			if hasPath {
+				f.path = createPath(path)
+			}
+			replaced := false
+			for k := range fields {
+				if fields[k].name == name {
+					fields[k] = f
+					replaced = true
+				}
+			}
+			if !replaced {
+				fields = append(fields, f)
+			}
+		}
+	}
+	collect(t, nil)
+
+	fieldCache.Store(key, fields)
+	return fields
+}
+
+// fieldTag returns the name and the options of a field from its tag, or from its json tag
+// A name "-" skips the field
+func fieldTag(sf reflect.StructField, tagName string) (name string, opts string, tagged bool) {
+	tag, ok := sf.Tag.Lookup(tagName)
+	if !ok && tagName != "json" {
+		tag, ok = sf.Tag.Lookup("json")
+	}
+	if !ok {
+		return "", "", false
+	}
+	if tag == "-" {
+		return "-", "", false
+	}
+	if idx := strings.IndexByte(tag, ','); idx >= 0 {
+		return tag[:idx], tag[idx:], true
+	}
+	return tag, "", true
+}
+
+// FromValue creates a Json from any Go value, without marshalling.
+// Struct fields are named by their json tag, see TagName, and the omitempty and string options are honored.
+// Fields with a path tag, ie `jsonmap:"query.bool.filter[0].term.host"`, are set at their path, see Encode
+// Jsonizers, json.Marshalers and encoding.TextMarshalers are used to convert their values.
+// Returns a *BindError if a value can't be converted, ie a channel
+func FromValue(v interface{}, opt ...BindOption) (*Json, error) {
+	c := &converter{opts: newBindOptions(opt...)}
+	data, err := c.convert(reflect.ValueOf(v), "", 0)
+	if err != nil {
+		return nil, err
+	}
+	return &Json{data: data}, nil
+}
+
+// FromStruct creates a Json object from a struct or a pointer to a struct, see FromValue
+func FromStruct(v interface{}, opt ...BindOption) (*Json, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, &BindError{msg: fmt.Sprintf("can't convert %T, expected a struct", v)}
+	}
+	return FromValue(v, opt...)
+}
+
+// Encode sets the fields of a struct, or a pointer to a struct, in the current json, the inverse of Decode.
+// Fields with a path tag, ie `jsonmap:"query.bool.filter[0].term.host"`, are set at their path as with Set,
+// other fields at their key, see FromValue. Slices tagged with a wildcard path, ie `jsonmap:"hits[*]._id"`,
+// are spread over the members or the items matched by their first wildcard, so they decode back. The current json must be an object or null.
+// Encode is atomic : on error, the json is left unchanged
+// Example : New().Encode(&Config{Host: "localhost", Size: 10})
+func (j *Json) Encode(v interface{}, opt ...BindOption) error {
+	j.modify()
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return &BindError{msg: fmt.Sprintf("can't encode %T, expected a struct", v)}
+	}
+
+	data := cloneValue(j.data)
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	if _, ok := objectOf(data); !ok {
+		return j.typeError("object")
+	}
+	c := &converter{opts: newBindOptions(opt...)}
+	data, err := c.convertStruct(rv, data, j.path, 0)
+	if err != nil {
+		return err
+	}
+	j.data = data
+	return nil
+}
+
+// converter converts Go values to json data, see FromValue
+type converter struct {
+	opts BindOptions
+}
+
+func (c *converter) convert(rv reflect.Value, path string, depth int) (interface{}, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	if depth > maxBindDepth {
+		return nil, &BindError{Path: path, msg: "too deep or cyclic value"}
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+	}
+
+	// Values converting themselves
+	switch rv.Type() {
+	case jsonType:
+		j := rv.Interface().(Json)
+		return cloneValue(dataOf(&j)), nil
+	case jsonPtrType:
+		return cloneValue(dataOf(rv.Interface().(*Json))), nil
+	case timeType, numberType:
+		return rv.Interface(), nil
+	}
+	if rv.Type().Implements(jsonizerType) {
+		return cloneValue(dataOf(jsonize(rv.Interface().(Jsonizer)))), nil
+	}
+	if rv.Type().Implements(marshalerType) {
+		data, err := rv.Interface().(json.Marshaler).MarshalJSON()
+		if err != nil {
+			return nil, &BindError{Path: path, msg: err.Error(), err: err}
+		}
+		v, err := decode(data, defaultParseOptions)
+		if err != nil {
+			return nil, &BindError{Path: path, msg: err.Error(), err: err}
+		}
+		return v, nil
+	}
+	if rv.Type().Implements(textMarshalerType) {
+		text, err := rv.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, &BindError{Path: path, msg: err.Error(), err: err}
+		}
+		return string(text), nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32:
+		// keeps the float32 digits, ie 0.1 instead of 0.10000000149011612
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(rv.Float(), 'g', -1, 32), 64)
+		return f, nil
+	case reflect.Float64:
+		return rv.Float(), nil
+
+	case reflect.Ptr, reflect.Interface:
+		return c.convert(rv.Elem(), path, depth+1)
+
+	case reflect.Struct:
+		return c.convertStruct(rv, make(map[string]interface{}), path, depth)
+
+	case reflect.Map:
+		o := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k, err := mapKeyString(iter.Key())
+			if err != nil {
+				return nil, &BindError{Path: path, msg: err.Error()}
+			}
+			v, err := c.convert(iter.Value(), keyPath(path, k), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			o[k] = v
+		}
+		return o, nil
+
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return base64.StdEncoding.EncodeToString(rv.Bytes()), nil
+		}
+		a := make([]interface{}, rv.Len())
+		for i := range a {
+			v, err := c.convert(rv.Index(i), indexPath(path, i), depth+1)
+			if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+
+	return nil, &BindError{Path: path, msg: fmt.Sprintf("unsupported type %s", rv.Type())}
+}
+
+// convertStruct sets the fields of a struct in the object o
+func (c *converter) convertStruct(rv reflect.Value, o interface{}, path string, depth int) (interface{}, error) {
+	for _, f := range structFields(rv.Type(), c.opts.TagName) {
+		fv, ok := fieldByIndex(rv, f.index, false)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) {
+			continue
+		}
+
+		keys := f.path
+		fieldPath := joinPath(path, f.name)
+		if keys == nil {
+			keys = []pathKey{{name: f.name}}
+			fieldPath = keyPath(path, f.name)
+		}
+
+		v, err := c.convert(fv, fieldPath, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if f.asString {
+			v = quoteScalar(v)
+		}
+		if hasWildcard(keys) {
+			o, ok = spreadValue(o, keys, v)
+		} else {
+			o, ok = setValue(o, keys, v)
+		}
+		if !ok {
+			return nil, &BindError{Path: fieldPath, msg: "can't set the path"}
+		}
+	}
+	return o, nil
+}
+
+// spreadValue sets the items of the array v at the matches of the first wildcard of keys, the inverse of Decode :
+// at the members of an existing object in key order, else at the indexes of an array
+func spreadValue(o interface{}, keys []pathKey, v interface{}) (interface{}, bool) {
+	if v == nil {
+		return o, true
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return o, false
+	}
+
+	w := 0
+	for !keys[w].wildcard {
+		w++
+	}
+	var names []string
+	if parent, found := getValue(o, keys[:w]); found {
+		if _, isObject := objectOf(parent); isObject {
+			names = objectKeys(parent)
+			if len(items) > len(names) {
+				return o, false
+			}
+		}
+	}
+
+	itemKeys := make([]pathKey, len(keys))
+	copy(itemKeys, keys)
+	for i, item := range items {
+		if names != nil {
+			itemKeys[w] = pathKey{name: names[i]}
+		} else {
+			itemKeys[w] = pathKey{name: strconv.Itoa(i), index: i, isIndex: true, bracket: true}
+		}
+		if o, ok = setValue(o, itemKeys, item); !ok {
+			return o, false
+		}
+	}
+	return o, true
+}
+
+// fieldByIndex returns a field of a struct, through embedded pointers
+// Nil embedded pointers are allocated if alloc, otherwise the field is not found
+func fieldByIndex(rv reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return rv, true
+}
+
+// mapKeyString converts a map key to a json key
+func mapKeyString(k reflect.Value) (string, error) {
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+	if tm, ok := k.Interface().(encoding.TextMarshaler); ok {
+		text, err := tm.MarshalText()
+		return string(text), err
+	}
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", k.Type())
+}
+
+// quoteScalar converts a scalar to its json string, for the ",string" option
+func quoteScalar(v interface{}) interface{} {
+	switch kindOf(v) {
+	case BoolKind, NumberKind, StringKind:
+		b, err := json.Marshal(v)
+		if err == nil {
+			return string(b)
+		}
+	}
+	return v
+}
+
+// isEmptyValue checks if a value is empty, for the omitempty option
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,244 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+type person struct {
+	name string
+}
+
+func (p *person) JSON() *jsonmap.Json {
+	j := jsonmap.New()
+	j.Set("name", p.name)
+	return j
+}
+
+type searchQuery struct {
+	Index   string            `json:"index"`
+	Size    int               `json:"size,omitempty"`
+	From    int               `json:"from,omitempty"`
+	Total   uint64            `json:"total,string"`
+	Boost   float32           `json:"boost"`
+	Tags    []string          `json:"tags"`
+	Empty   []string          `json:"empty"`
+	Params  map[int]bool      `json:"params"`
+	Owner   *person           `json:"owner"`
+	Nobody  *person           `json:"nobody"`
+	Date    time.Time         `json:"date"`
+	IP      net.IP            `json:"ip"`
+	Raw     json.RawMessage   `json:"raw"`
+	Body    *jsonmap.Json     `json:"body"`
+	Extra   map[string]string `json:"extra,omitempty"`
+	Ignored string            `json:"-"`
+	Meta
+}
+
+func TestFromValue(t *testing.T) {
+	q := searchQuery{
+		Index:  "logs",
+		Size:   10,
+		Total:  12,
+		Boost:  0.1,
+		Tags:   []string{"a", "b"},
+		Params: map[int]bool{1: true},
+		Owner:  &person{"john"},
+		Date:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		IP:     net.ParseIP("10.0.0.1"),
+		Raw:    json.RawMessage(`{"a":[1]}`),
+		Body:   jsonmap.FromString(`{"match_all":{}}`),
+		Meta:   Meta{Index: "meta", ID: "1"},
+	}
+
+	j, err := jsonmap.FromStruct(&q)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"index": "logs",
+		"size": 10,
+		"total": "12",
+		"boost": 0.1,
+		"tags": ["a", "b"],
+		"empty": null,
+		"params": { "1": true },
+		"owner": { "name": "john" },
+		"nobody": null,
+		"date": "2020-01-02T03:04:05Z",
+		"ip": "10.0.0.1",
+		"raw": { "a": [1] },
+		"body": { "match_all": {} },
+		"_index": "meta",
+		"_id": "1"
+	}`, j.Stringify())
+
+	// values are not shared
+	j.Set("body.match_all.boost", 1)
+	assert.False(t, q.Body.Has("match_all.boost"))
+	assert.Equal(t, 0.1, j.Get("boost").AsFloat())
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), j.Get("date").AsTime())
+
+	// decoded back
+	var decoded searchQuery
+	assert.NoError(t, j.Decode(&decoded))
+	assert.Equal(t, q.Tags, decoded.Tags)
+	assert.Equal(t, q.Total, decoded.Total)
+	assert.Equal(t, q.Meta, decoded.Meta)
+
+	// values
+	for v, expected := range map[interface{}]string{
+		nil:                 "null",
+		"a":                 `"a"`,
+		42:                  "42",
+		level(2):            "2",
+		[2]int{1, 2}:        "[1,2]",
+		&person{"jane"}:     `{"name":"jane"}`,
+		json.Number("1.50"): "1.50",
+	} {
+		j, err := jsonmap.FromValue(v)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, j.Stringify())
+	}
+	j, err = jsonmap.FromValue(map[string]interface{}{"a": []interface{}{1, map[string]int{"b": 2}}})
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":[1,{"b":2}]}`, j.Stringify())
+	assert.Equal(t, int64(2), j.Get("a[1].b").AsInt())
+}
+
+func TestFromValueTagName(t *testing.T) {
+	var s = struct {
+		Name  string `yaml:"nom" json:"name"`
+		Count int    `json:"count"`
+		Skip  string `yaml:"-"`
+		Other string
+	}{"a", 1, "b", "c"}
+
+	j, err := jsonmap.FromValue(s, jsonmap.TagName("yaml"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{ "nom": "a", "count": 1, "Other": "c" }`, j.Stringify())
+}
+
+func TestFromValueErrors(t *testing.T) {
+	_, err := jsonmap.FromStruct([]int{1})
+	assert.EqualError(t, err, "jsonmap: can't convert []int, expected a struct")
+
+	_, err = jsonmap.FromValue(map[string]interface{}{"a": []interface{}{make(chan int)}})
+	assert.EqualError(t, err, "jsonmap: unsupported type chan int at 'a[0]'")
+
+	_, err = jsonmap.FromValue(map[float64]int{1: 1})
+	assert.EqualError(t, err, "jsonmap: unsupported map key type float64")
+
+	type node struct {
+		Next *node `json:"next"`
+	}
+	n := &node{}
+	n.Next = n
+	_, err = jsonmap.FromValue(n)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "too deep or cyclic value")
+}
+
+func TestEncode(t *testing.T) {
+	type config struct {
+		Index string   `json:"index"`
+		Size  int      `json:"size,omitempty" jsonmap:"size"`
+		Host  string   `jsonmap:"query.bool.filter[0].term.host"`
+		Level string   `jsonmap:"query.bool.filter[1].term.level"`
+		From  string   `jsonmap:"query.bool.must.range.date.gte"`
+		Tags  []string `jsonmap:"query.bool.must.terms.tags"`
+		Skip  string   `jsonmap:"-"`
+	}
+	c := config{Index: "logs", Host: "web-1", Level: "error", From: "now-1h", Tags: []string{"a"}, Skip: "x"}
+
+	j := jsonmap.New()
+	assert.NoError(t, j.Encode(&c))
+	assert.JSONEq(t, `{
+		"index": "logs",
+		"query": { "bool": {
+			"filter": [{ "term": { "host": "web-1" } }, { "term": { "level": "error" } }],
+			"must": { "range": { "date": { "gte": "now-1h" } }, "terms": { "tags": ["a"] } }
+		} }
+	}`, j.Stringify())
+
+	// fields are set in the existing json
+	j = jsonmap.FromString(`{ "size": 10, "query": { "bool": { "must_not": [] } } }`)
+	assert.NoError(t, j.Encode(config{Size: 5}))
+	assert.JSONEq(t, `{
+		"index": "",
+		"size": 5,
+		"query": { "bool": {
+			"must_not": [],
+			"filter": [{ "term": { "host": "" } }, { "term": { "level": "" } }],
+			"must": { "range": { "date": { "gte": "" } }, "terms": { "tags": null } }
+		} }
+	}`, j.Stringify())
+
+	// decodes back
+	var back config
+	j = jsonmap.New()
+	assert.NoError(t, j.Encode(c))
+	assert.NoError(t, j.Decode(&back))
+	c.Skip = ""
+	assert.Equal(t, c, back)
+
+	// FromValue honors path tags
+	j, err := jsonmap.FromValue(struct {
+		Total int `jsonmap:"hits.total.value"`
+	}{3})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{ "hits": { "total": { "value": 3 } } }`, j.Stringify())
+
+	// errors leave the json unchanged
+	type tag struct {
+		Index string `json:"index"`
+		Tag   string `jsonmap:"tags[]"`
+	}
+	j = jsonmap.FromString(`{ "tags": {} }`)
+	err = j.Encode(tag{"logs", "a"})
+	assert.EqualError(t, err, "jsonmap: can't set the path at 'tags[]'")
+	assert.JSONEq(t, `{ "tags": {} }`, j.Stringify())
+	assert.EqualError(t, jsonmap.FromString(`[]`).Encode(c), "jsonmap: value is array, expected object")
+	assert.EqualError(t, jsonmap.New().Encode([]int{}), "jsonmap: can't encode []int, expected a struct")
+}
+
+func TestEncodeWildcards(t *testing.T) {
+	type result struct {
+		IDs    []string `jsonmap:"hits.hits[*]._id"`
+		Counts []int    `jsonmap:"aggs.*.doc_count"`
+	}
+	j := jsonmap.FromString(`{
+		"hits": { "hits": [{ "_id": "1", "_score": 2 }, { "_id": "2", "_score": 1 }] },
+		"aggs": { "b": { "doc_count": 3 }, "a": { "doc_count": 5 } }
+	}`)
+	var res result
+	assert.NoError(t, j.Decode(&res))
+	assert.Equal(t, result{IDs: []string{"1", "2"}, Counts: []int{5, 3}}, res)
+
+	// decodes back
+	res.IDs[1], res.Counts[0] = "x", 4
+	assert.NoError(t, j.Encode(res))
+	assert.JSONEq(t, `{
+		"hits": { "hits": [{ "_id": "1", "_score": 2 }, { "_id": "x", "_score": 1 }] },
+		"aggs": { "b": { "doc_count": 3 }, "a": { "doc_count": 4 } }
+	}`, j.Stringify())
+	var back result
+	assert.NoError(t, j.Decode(&back))
+	assert.Equal(t, res, back)
+
+	// arrays are created
+	j = jsonmap.New()
+	assert.NoError(t, j.Encode(result{IDs: []string{"1", "2"}}))
+	assert.JSONEq(t, `{ "hits": { "hits": [{ "_id": "1" }, { "_id": "2" }] } }`, j.Stringify())
+	back = result{}
+	assert.NoError(t, j.Decode(&back))
+	assert.Equal(t, []string{"1", "2"}, back.IDs)
+
+	// more items than members
+	j = jsonmap.FromString(`{ "aggs": { "a": {} } }`)
+	assert.EqualError(t, j.Encode(result{Counts: []int{1, 2}}), "jsonmap: can't set the path at 'aggs.*.doc_count'")
+	assert.JSONEq(t, `{ "aggs": { "a": {} } }`, j.Stringify())
+}
@@ -0,0 +1,216 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// StringifyOptions are our Stringify options
+type StringifyOptions struct {
+	Canonical bool
+}
+
+// StringifyOption is a stringify option setter
+type StringifyOption func(o *StringifyOptions)
+
+func newStringifyOptions(opt ...StringifyOption) StringifyOptions {
+	opts := StringifyOptions{}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return opts
+}
+
+// Canonical formats the json as defined by the JSON Canonicalization Scheme (RFC 8785) :
+// sorted keys, no whitespace, minimal string escaping and ECMAScript number formatting.
+// Numbers are formatted as float64, non-finite numbers are not allowed
+// default : false
+func Canonical(v bool) StringifyOption {
+	return func(opts *StringifyOptions) {
+		opts.Canonical = v
+	}
+}
+
+// maxCanonicalDepth limits the nesting of canonicalized values, to detect cycles
+const maxCanonicalDepth = 1000
+
+// canonicalize formats v as defined by RFC 8785
+func canonicalize(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeCanonical(&buf, v, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}, depth int) error {
+	if depth > maxCanonicalDepth {
+		return errors.New("jsonmap: can't canonicalize a too deep or cyclic value")
+	}
+
+	if o, ok := objectOf(v); ok {
+		keys := make([]string, 0, len(o))
+		for k := range o {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return lessUTF16(keys[i], keys[j])
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, o[k], depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+
+	switch cv := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(cv))
+	case string:
+		writeCanonicalString(buf, cv)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range cv {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, item, depth+1); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	default:
+		if f, ok := numberOf(v); ok {
+			s, err := formatCanonicalNumber(f)
+			if err != nil {
+				return err
+			}
+			buf.WriteString(s)
+			return nil
+		}
+
+		// Other Go values (ie time.Time) are formatted from their json
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		generic, err := decode(data, ParseOptions{UseNumber: true})
+		if err != nil {
+			return err
+		}
+		return writeCanonical(buf, generic, depth+1)
+	}
+	return nil
+}
+
+// lessUTF16 compares two strings by their UTF-16 code units
+func lessUTF16(a string, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeCanonicalString writes a string escaping only '"', '\' and control characters
+// Invalid UTF-8 is replaced by U+FFFD
+func writeCanonicalString(buf *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buf.WriteRune(r)
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < 0x20 {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hex[c>>4])
+				buf.WriteByte(hex[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+	buf.WriteByte('"')
+}
+
+// formatCanonicalNumber formats a number like ECMAScript Number.prototype.toString
+func formatCanonicalNumber(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", errors.New("jsonmap: can't canonicalize a non-finite number")
+	}
+	if f == 0 {
+		return "0", nil
+	}
+
+	sign := ""
+	if f < 0 {
+		sign = "-"
+		f = -f
+	}
+
+	// shortest digits, ie 1.2345e+06 => digits "12345", n = 7
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := e[:strings.IndexByte(e, 'e')], e[strings.IndexByte(e, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	n, k := x+1, len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k), nil
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:], nil
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits, nil
+	}
+
+	res := sign + digits[:1]
+	if k > 1 {
+		res += "." + digits[1:]
+	}
+	if n-1 >= 0 {
+		return res + "e+" + strconv.Itoa(n-1), nil
+	}
+	return res + "e" + strconv.Itoa(n-1), nil
+}
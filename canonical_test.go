@@ -0,0 +1,117 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedKeys(t *testing.T) {
+	j, _ := jsonmap.ParseString(`{"b":1,"c":{"z":1,"y":2},"a":[3]}`, jsonmap.Ordered(true))
+	assert.Equal(t, []string{"a", "b", "c"}, j.SortedKeys())
+	assert.Equal(t, []string{"b", "c", "a"}, j.Keys())
+	assert.Equal(t, []string{"a", "b", "c"}, jsonmap.FromString(`{"b":1,"c":2,"a":3}`).SortedKeys())
+	assert.Nil(t, jsonmap.FromString(`[1]`).SortedKeys())
+
+	var paths []string
+	j.ForEachSorted(func(k string, v *jsonmap.Json) bool {
+		paths = append(paths, v.Path())
+		return k != "b"
+	})
+	assert.Equal(t, []string{"a", "b"}, paths)
+
+	paths = nil
+	j.Get("a").ForEachSorted(func(k string, v *jsonmap.Json) bool {
+		paths = append(paths, v.Path())
+		return true
+	})
+	assert.Equal(t, []string{"a[0]"}, paths)
+}
+
+func TestCanonical(t *testing.T) {
+	// RFC 8785 section 3.2.2
+	j := jsonmap.FromString(`{
+		"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+		"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+		"literals": [null, true, false]
+	}`)
+	assert.Equal(t, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`, j.Stringify(jsonmap.Canonical(true)))
+	assert.Equal(t, j.Stringify(), j.Stringify(jsonmap.Canonical(false)))
+
+	// RFC 8785 section 3.2.3, sorted by UTF-16 code units
+	j = jsonmap.FromString(`{
+		"€": "Euro Sign",
+		"\r": "Carriage Return",
+		"דּ": "Hebrew Letter Dalet With Dagesh",
+		"1": "One",
+		"😀": "Emoji: Grinning Face",
+		"\u0080": "Control",
+		"ö": "Latin Small Letter O With Diaeresis"
+	}`)
+	sorted, err := jsonmap.Parse(j.Bytes(jsonmap.Canonical(true)), jsonmap.Ordered(true))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"\r", "1", "\u0080", "ö", "€", "\U0001F600", "דּ"}, sorted.Keys())
+
+	// same document, same output
+	a, _ := jsonmap.ParseString(`{"b":[1.0,{"y":"<>","x":1e2}],"a":true}`, jsonmap.Ordered(true), jsonmap.UseNumber(true))
+	b := jsonmap.FromString(`{ "a": true, "b": [1, { "x": 100, "y": "<>" }] }`)
+	assert.Equal(t, `{"a":true,"b":[1,{"x":100,"y":"<>"}]}`, a.Stringify(jsonmap.Canonical(true)))
+	assert.Equal(t, a.Stringify(jsonmap.Canonical(true)), b.Stringify(jsonmap.Canonical(true)))
+
+	// Go values
+	j = jsonmap.New()
+	j.Set("time", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+	j.Set("int", 42)
+	j.Set("number", json.Number("1.50"))
+	j.Set("struct", struct {
+		B int `json:"b"`
+		A int `json:"a"`
+	}{1, 2})
+	assert.Equal(t, `{"int":42,"number":1.5,"struct":{"a":2,"b":1},"time":"2020-01-02T03:04:05Z"}`, j.Stringify(jsonmap.Canonical(true)))
+
+	// errors
+	j.Set("nan", math.NaN())
+	assert.Equal(t, "null", j.Stringify(jsonmap.Canonical(true)))
+	j = jsonmap.New()
+	j.Set("self", j)
+	assert.Equal(t, "null", j.Stringify(jsonmap.Canonical(true)))
+}
+
+func TestCanonicalNumbers(t *testing.T) {
+	// RFC 8785 appendix B
+	tests := map[float64]string{
+		0:                         "0",
+		math.Copysign(0, -1):      "0",
+		5e-324:                    "5e-324",
+		-5e-324:                   "-5e-324",
+		1.7976931348623157e308:    "1.7976931348623157e+308",
+		-1.7976931348623157e308:   "-1.7976931348623157e+308",
+		9007199254740992:          "9007199254740992",
+		-9007199254740992:         "-9007199254740992",
+		295147905179352830000:     "295147905179352830000",
+		9.999999999999997e+22:     "9.999999999999997e+22",
+		1e+23:                     "1e+23",
+		1.0000000000000001e+23:    "1.0000000000000001e+23",
+		999999999999999700000:     "999999999999999700000",
+		999999999999999900000:     "999999999999999900000",
+		1e+21:                     "1e+21",
+		9.999999999999997e-7:      "9.999999999999997e-7",
+		0.000001:                  "0.000001",
+		333333333.3333332:         "333333333.3333332",
+		333333333.33333325:        "333333333.33333325",
+		333333333.3333333:         "333333333.3333333",
+		-0.0000033333333333333333: "-0.0000033333333333333333",
+		1e-7:                      "1e-7",
+		123.456:                   "123.456",
+	}
+
+	for f, expected := range tests {
+		j := jsonmap.New()
+		j.Set("n", f)
+		assert.Equal(t, `{"n":`+expected+`}`, j.Stringify(jsonmap.Canonical(true)), expected)
+	}
+}
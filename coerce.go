@@ -0,0 +1,86 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// numberRegexp matches a json number
+var numberRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
+// Coerce returns the current Json in coerce mode.
+// In coerce mode, accessors convert values between kinds:
+//   - numeric strings to numbers, ie "42" => 42
+//   - booleans to numbers, ie true => 1
+//   - numbers and booleans to strings, ie 42 => "42"
+//   - "true", "false", "1", "0" strings and numbers to booleans, ie 1 => true
+//   - epoch milliseconds to time.Time
+//
+// Jsons got from a Json in coerce mode are also in coerce mode.
+// Underlying data is shared
+func (j *Json) Coerce() *Json {
+	return &Json{
+		data:   j.data,
+		path:   j.path,
+		coerce: true,
+		lazy:   j.lazy,
+	}
+}
+
+// as returns the underlying data converted to kind in coerce mode.
+// Returns the underlying data if not in coerce mode or if not convertible
+func (j *Json) as(kind Kind) interface{} {
+	j.load()
+	if !j.coerce || kindOf(j.data) == kind {
+		return j.data
+	}
+
+	switch kind {
+	case NumberKind:
+		switch v := j.data.(type) {
+		case string:
+			if s := strings.TrimSpace(v); numberRegexp.MatchString(s) {
+				return json.Number(s)
+			}
+		case bool:
+			if v {
+				return 1
+			}
+			return 0
+		}
+
+	case StringKind:
+		switch v := j.data.(type) {
+		case json.Number:
+			return v.String()
+		case float32, float64:
+			return strconv.FormatFloat(reflect.ValueOf(v).Float(), 'f', -1, 64)
+		case int, int8, int16, int32, int64:
+			return strconv.FormatInt(reflect.ValueOf(v).Int(), 10)
+		case uint, uint8, uint16, uint32, uint64:
+			return strconv.FormatUint(reflect.ValueOf(v).Uint(), 10)
+		case bool:
+			return strconv.FormatBool(v)
+		case time.Time:
+			return v.Format(time.RFC3339Nano)
+		}
+
+	case BoolKind:
+		switch v := j.data.(type) {
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b
+			}
+		case json.Number, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			if f, ok := (&Json{data: v}).asFloat(); ok {
+				return f != 0
+			}
+		}
+	}
+
+	return j.data
+}
@@ -0,0 +1,93 @@
+package jsonmap_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonCoerce = `
+{
+	"int": "42",
+	"float": " 3.14 ",
+	"notnumber": "42abc",
+	"hex": "0x10",
+	"true": "true",
+	"one": 1,
+	"zero": 0.0,
+	"number": 1234.5,
+	"bool": true,
+	"date": "2020-03-01T10:20:30Z",
+	"ms": 1583058030000,
+	"sub": { "value": "12" },
+	"items": ["1", "2"]
+}
+`
+
+func TestCoerce(t *testing.T) {
+	j := jsonmap.FromString(jsonCoerce)
+	assert.Equal(t, int64(0), j.Get("int").AsInt())
+
+	c := j.Coerce()
+	assert.Equal(t, int64(42), c.Get("int").AsInt())
+	assert.Equal(t, uint64(42), c.Get("int").AsUint())
+	assert.Equal(t, float64(42), c.Get("int").AsFloat())
+	assert.Equal(t, 3.14, c.Get("float").AsFloat())
+	assert.Equal(t, int64(3), c.Get("float").AsInt())
+	assert.Equal(t, int64(-1), c.Get("notnumber").AsIntOr(-1))
+	assert.Equal(t, int64(-1), c.Get("hex").AsIntOr(-1))
+	assert.Equal(t, int64(1), c.Get("bool").AsInt())
+
+	assert.True(t, c.Get("true").AsBool())
+	assert.True(t, c.Get("one").AsBool())
+	assert.False(t, c.Get("zero").AsBoolOr(true))
+	assert.True(t, c.Get("notnumber").AsBoolOr(true))
+
+	assert.Equal(t, "1", c.Get("one").AsString())
+	assert.Equal(t, "1234.5", c.Get("number").AsString())
+	assert.Equal(t, "true", c.Get("bool").AsString())
+	assert.Equal(t, "def", c.Get("sub").AsStringOr("def"))
+
+	assert.True(t, time.Date(2020, 3, 1, 10, 20, 30, 0, time.UTC).Equal(c.Get("ms").AsTime()))
+	assert.True(t, time.Date(2020, 3, 1, 10, 20, 30, 0, time.UTC).Equal(c.Get("date").AsTime()))
+
+	// propagation
+	assert.Equal(t, int64(12), c.Get("sub").Get("value").AsInt())
+	var sum int64
+	c.Get("items").ForEach(func(k string, v *jsonmap.Json) bool {
+		sum += v.AsInt()
+		return true
+	})
+	assert.Equal(t, int64(3), sum)
+
+	// data is shared
+	c.Set("int", "43")
+	assert.Equal(t, "43", j.Get("int").AsString())
+}
+
+func TestCoerceStrict(t *testing.T) {
+	c := jsonmap.FromString(jsonCoerce).Coerce()
+
+	i, err := c.Get("int").Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), i)
+
+	_, err = c.Get("float").Int64()
+	assert.EqualError(t, err, "jsonmap: value at 'float' is string, expected int64")
+
+	s, err := c.Get("number").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "1234.5", s)
+
+	b, err := c.Get("true").Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	_, err = c.Get("sub").Bool()
+	assert.EqualError(t, err, "jsonmap: value at 'sub' is object, expected boolean")
+
+	_, err = c.Get("notnumber").Time()
+	assert.EqualError(t, err, "jsonmap: value at 'notnumber' is string, expected time")
+}
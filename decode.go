@@ -0,0 +1,330 @@
+package jsonmap
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Decode stores the current json into v, which must be a non-nil pointer, without marshalling.
+// Struct fields are matched by their json tag, see TagName, or by their name case-insensitively.
+// Fields with a path tag, ie `jsonmap:"aggregations.total.value"`, are decoded from the value at their path,
+// relative to the struct object. Paths with wildcards are decoded from the array of their values.
+// json.Unmarshalers and encoding.TextUnmarshalers are used to decode their values, and
+// Json or *Json fields receive a copy of the json.
+// In coerce mode, scalars are converted as with the As* accessors.
+// Returns a *TypeError with the path of the value if it doesn't match the Go type
+func (j *Json) Decode(v interface{}, opt ...BindOption) error {
+	j.load()
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &BindError{msg: fmt.Sprintf("can't decode into %T, expected a non-nil pointer", v)}
+	}
+	d := &decoder{opts: newBindOptions(opt...)}
+	return d.decode(j, rv.Elem())
+}
+
+// decoder decodes jsons into Go values, see Decode
+type decoder struct {
+	opts BindOptions
+}
+
+// child returns the json of a value in n
+func child(n *Json, data interface{}, path string) *Json {
+	return &Json{data: data, path: path, coerce: n.coerce}
+}
+
+func (d *decoder) decode(n *Json, rv reflect.Value) error {
+	switch rv.Type() {
+	case jsonType:
+		rv.Set(reflect.ValueOf(Json{data: cloneValue(n.data), coerce: n.coerce}))
+		return nil
+	case jsonPtrType:
+		rv.Set(reflect.ValueOf(&Json{data: cloneValue(n.data), coerce: n.coerce}))
+		return nil
+	}
+
+	if n.data == nil {
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+
+	if rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(n, rv.Elem())
+	}
+
+	// Values decoding themselves
+	switch rv.Type() {
+	case timeType:
+		t, err := n.Time()
+		if err != nil {
+			return err
+		}
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case numberType:
+		if kindOf(n.data) != NumberKind && !n.coerce {
+			return n.typeError("number")
+		}
+		s, ok := n.Coerce().asString()
+		if !ok || !numberRegexp.MatchString(s) {
+			return n.typeError("number")
+		}
+		rv.SetString(s)
+		return nil
+	}
+	if rv.CanAddr() {
+		pv := rv.Addr()
+		if pv.Type().Implements(unmarshalerType) {
+			data, err := json.Marshal(n.data)
+			if err == nil {
+				err = pv.Interface().(json.Unmarshaler).UnmarshalJSON(data)
+			}
+			if err != nil {
+				return &BindError{Path: n.path, msg: err.Error(), err: err}
+			}
+			return nil
+		}
+		if pv.Type().Implements(textUnmarshalType) {
+			s, err := n.String()
+			if err != nil {
+				return err
+			}
+			if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+				return &BindError{Path: n.path, msg: err.Error(), err: err}
+			}
+			return nil
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() > 0 {
+			return &BindError{Path: n.path, msg: fmt.Sprintf("can't decode into %s", rv.Type())}
+		}
+		rv.Set(reflect.ValueOf(plainValue(n.data, 0)))
+		return nil
+
+	case reflect.Bool:
+		b, err := n.Bool()
+		if err != nil {
+			return err
+		}
+		rv.SetBool(b)
+		return nil
+
+	case reflect.String:
+		s, err := n.String()
+		if err != nil {
+			return err
+		}
+		rv.SetString(s)
+		return nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := n.Int64()
+		if err != nil || rv.OverflowInt(i) {
+			return n.typeError(rv.Type().String())
+		}
+		rv.SetInt(i)
+		return nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := n.Uint64()
+		if err != nil || rv.OverflowUint(u) {
+			return n.typeError(rv.Type().String())
+		}
+		rv.SetUint(u)
+		return nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := n.Float64()
+		if err != nil || rv.OverflowFloat(f) {
+			return n.typeError(rv.Type().String())
+		}
+		rv.SetFloat(f)
+		return nil
+
+	case reflect.Struct:
+		return d.decodeStruct(n, rv)
+
+	case reflect.Map:
+		return d.decodeMap(n, rv)
+
+	case reflect.Slice:
+		if s, ok := n.data.(string); ok && rv.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(s)
+			if err != nil {
+				return &BindError{Path: n.path, msg: "invalid base64 string", err: err}
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		a, err := n.Array()
+		if err != nil {
+			return err
+		}
+		s := reflect.MakeSlice(rv.Type(), len(a), len(a))
+		for i, item := range a {
+			if err := d.decode(child(n, item, indexPath(n.path, i)), s.Index(i)); err != nil {
+				return err
+			}
+		}
+		rv.Set(s)
+		return nil
+
+	case reflect.Array:
+		a, err := n.Array()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < rv.Len(); i++ {
+			if i >= len(a) {
+				rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				continue
+			}
+			if err := d.decode(child(n, a[i], indexPath(n.path, i)), rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return &BindError{Path: n.path, msg: fmt.Sprintf("unsupported type %s", rv.Type())}
+}
+
+// decodeStruct decodes an object into a struct, unknown keys are ignored
+func (d *decoder) decodeStruct(n *Json, rv reflect.Value) error {
+	o, err := n.Object()
+	if err != nil {
+		return n.typeError(rv.Type().String())
+	}
+
+	fields := structFields(rv.Type(), d.opts.TagName)
+	for _, k := range objectKeys(n.data) {
+		f, ok := findField(fields, k)
+		if !ok {
+			continue
+		}
+		fv, _ := fieldByIndex(rv, f.index, true)
+		if err := d.decodeField(child(n, o[k], keyPath(n.path, k)), f, fv); err != nil {
+			return err
+		}
+	}
+
+	// fields bound to a path
+	for _, f := range fields {
+		if f.path == nil {
+			continue
+		}
+		var data interface{}
+		if hasWildcard(f.path) {
+			values := []interface{}{}
+			walkValues(n.data, f.path, location{}, func(v interface{}, at location) bool {
+				values = append(values, v)
+				return true
+			})
+			data = values
+		} else {
+			v, ok := getValue(n.data, f.path)
+			if !ok {
+				continue
+			}
+			data = v
+		}
+		fv, _ := fieldByIndex(rv, f.index, true)
+		if err := d.decodeField(child(n, data, joinPath(n.path, f.name)), f, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeField decodes the value of a struct field, honoring the string option
+func (d *decoder) decodeField(c *Json, f bindField, fv reflect.Value) error {
+	if s, ok := c.data.(string); ok && f.asString {
+		if v, err := decode([]byte(s), ParseOptions{UseNumber: true}); err == nil {
+			c.data = v
+		}
+	}
+	return d.decode(c, fv)
+}
+
+// findField finds the field of a key, case-insensitively if there is no exact match
+// Fields bound to a path are ignored
+func findField(fields []bindField, k string) (bindField, bool) {
+	for _, f := range fields {
+		if f.name == k && f.path == nil {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, k) && f.path == nil {
+			return f, true
+		}
+	}
+	return bindField{}, false
+}
+
+// decodeMap decodes an object into a map
+func (d *decoder) decodeMap(n *Json, rv reflect.Value) error {
+	o, err := n.Object()
+	if err != nil {
+		return n.typeError(rv.Type().String())
+	}
+
+	t := rv.Type()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(t, len(o)))
+	}
+	for _, k := range objectKeys(n.data) {
+		path := keyPath(n.path, k)
+		kv := reflect.New(t.Key()).Elem()
+		if err := decodeMapKey(k, kv); err != nil {
+			return &BindError{Path: path, msg: err.Error(), err: err}
+		}
+		ev := reflect.New(t.Elem()).Elem()
+		if err := d.decode(child(n, o[k], path), ev); err != nil {
+			return err
+		}
+		rv.SetMapIndex(kv, ev)
+	}
+	return nil
+}
+
+// decodeMapKey decodes a json key into a map key
+func decodeMapKey(k string, kv reflect.Value) error {
+	if tu, ok := kv.Addr().Interface().(encoding.TextUnmarshaler); ok && kv.Kind() != reflect.String {
+		return tu.UnmarshalText([]byte(k))
+	}
+	switch kv.Kind() {
+	case reflect.String:
+		kv.SetString(k)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(k, 10, 64)
+		if err != nil || kv.OverflowInt(i) {
+			return fmt.Errorf("invalid %s key", kv.Type())
+		}
+		kv.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(k, 10, 64)
+		if err != nil || kv.OverflowUint(u) {
+			return fmt.Errorf("invalid %s key", kv.Type())
+		}
+		kv.SetUint(u)
+		return nil
+	}
+	return fmt.Errorf("unsupported map key type %s", kv.Type())
+}
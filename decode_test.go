@@ -0,0 +1,265 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return errors.New("unknown level")
+	}
+	return nil
+}
+
+type upper string
+
+func (u *upper) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*u = upper(strings.ToUpper(s))
+	return nil
+}
+
+type Meta struct {
+	Index string `json:"_index"`
+	ID    string `json:"_id"`
+}
+
+type hit struct {
+	*Meta
+	Score   float32           `json:"_score"`
+	Source  *jsonmap.Json     `json:"_source"`
+	Tags    []string          `json:"tags"`
+	Counts  map[string]uint16 `json:"counts"`
+	Pos     [2]float64        `json:"pos"`
+	Date    time.Time         `json:"date"`
+	Level   level             `json:"level"`
+	Name    upper             `json:"name"`
+	IP      net.IP            `json:"ip"`
+	Raw     []byte            `json:"raw"`
+	Any     interface{}       `json:"any"`
+	Total   int64             `json:"total,string"`
+	Ignored string            `json:"-"`
+	Missing *string           `json:"missing"`
+	private string
+}
+
+const jsonHit = `{
+	"_index": "logs",
+	"_id": "1",
+	"_score": 1.5,
+	"_source": { "message": "hello" },
+	"tags": ["a", "b"],
+	"counts": { "x": 1, "y": 2 },
+	"pos": [1.5, 2.5, 3.5],
+	"date": "2020-01-02T03:04:05Z",
+	"level": "high",
+	"name": "john",
+	"ip": "10.0.0.1",
+	"raw": "aGVsbG8=",
+	"any": { "a": [1, "b"] },
+	"total": "42",
+	"-": "ignored",
+	"missing": null,
+	"private": "ignored",
+	"unknown": true
+}`
+
+func TestDecode(t *testing.T) {
+	var h hit
+	h.Ignored = "kept"
+	j := jsonmap.FromString(jsonHit)
+	assert.NoError(t, j.Decode(&h))
+
+	assert.Equal(t, "logs", h.Index)
+	assert.Equal(t, "1", h.ID)
+	assert.Equal(t, float32(1.5), h.Score)
+	assert.Equal(t, "hello", h.Source.Get("message").AsString())
+	assert.Equal(t, []string{"a", "b"}, h.Tags)
+	assert.Equal(t, map[string]uint16{"x": 1, "y": 2}, h.Counts)
+	assert.Equal(t, [2]float64{1.5, 2.5}, h.Pos)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), h.Date)
+	assert.Equal(t, level(2), h.Level)
+	assert.Equal(t, upper("JOHN"), h.Name)
+	assert.Equal(t, "10.0.0.1", h.IP.String())
+	assert.Equal(t, []byte("hello"), h.Raw)
+	assert.Equal(t, map[string]interface{}{"a": []interface{}{float64(1), "b"}}, h.Any)
+	assert.Equal(t, int64(42), h.Total)
+	assert.Equal(t, "kept", h.Ignored)
+	assert.Nil(t, h.Missing)
+	assert.Empty(t, h.private)
+
+	// the json is copied
+	h.Source.Set("message", "modified")
+	assert.Equal(t, "hello", j.Get("_source.message").AsString())
+
+	// like encoding/json
+	var expected hit
+	expected.Ignored = "kept"
+	assert.NoError(t, json.Unmarshal([]byte(jsonHit), &expected))
+	assert.Equal(t, expected.Meta, h.Meta)
+	assert.Equal(t, expected.Tags, h.Tags)
+	assert.Equal(t, expected.Counts, h.Counts)
+	assert.Equal(t, expected.Pos, h.Pos)
+	assert.Equal(t, expected.Any, h.Any)
+
+	// case insensitive, maps and pointers
+	var s struct {
+		UserName string
+		Scores   map[int]*float64
+	}
+	assert.NoError(t, jsonmap.FromString(`{ "username": "john", "scores": { "1": 1.5, "2": null } }`).Decode(&s))
+	assert.Equal(t, "john", s.UserName)
+	assert.Equal(t, 1.5, *s.Scores[1])
+	assert.Nil(t, s.Scores[2])
+
+	// ordered objects
+	var any interface{}
+	o, _ := jsonmap.ParseString(`{"b":{"c":1},"a":[{"d":2}]}`, jsonmap.Ordered(true))
+	assert.NoError(t, o.Decode(&any))
+	assert.Equal(t, map[string]interface{}{"b": map[string]interface{}{"c": float64(1)}, "a": []interface{}{map[string]interface{}{"d": float64(2)}}}, any)
+}
+
+func TestDecodeTagName(t *testing.T) {
+	var s struct {
+		Name  string `yaml:"nom" json:"name"`
+		Count int    `json:"count"`
+		Skip  string `yaml:"-"`
+	}
+	j := jsonmap.FromString(`{ "nom": "a", "name": "b", "count": 3, "skip": "c" }`)
+	assert.NoError(t, j.Decode(&s, jsonmap.TagName("yaml")))
+	assert.Equal(t, "a", s.Name)
+	assert.Equal(t, 3, s.Count)
+	assert.Empty(t, s.Skip)
+}
+
+func TestDecodeCoerce(t *testing.T) {
+	var s struct {
+		Count  int     `json:"count"`
+		Ratio  float64 `json:"ratio"`
+		Active bool    `json:"active"`
+		Label  string  `json:"label"`
+	}
+	j := jsonmap.FromString(`{ "count": "42", "ratio": "0.5", "active": "true", "label": 12 }`)
+	assert.Error(t, j.Decode(&s))
+	assert.NoError(t, j.Coerce().Decode(&s))
+	assert.Equal(t, 42, s.Count)
+	assert.Equal(t, 0.5, s.Ratio)
+	assert.True(t, s.Active)
+	assert.Equal(t, "12", s.Label)
+}
+
+func TestDecodeErrors(t *testing.T) {
+	var h hit
+	j := jsonmap.FromString(`{ "aggregations": { "hosts": { "buckets": [{ "doc_count": 1 }, { "doc_count": 1.5 }] } } }`)
+
+	var aggs struct {
+		Aggregations struct {
+			Hosts struct {
+				Buckets []struct {
+					DocCount int `json:"doc_count"`
+				} `json:"buckets"`
+			} `json:"hosts"`
+		} `json:"aggregations"`
+	}
+	err := j.Decode(&aggs)
+	var te *jsonmap.TypeError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, "aggregations.hosts.buckets[1].doc_count", te.Path)
+	assert.EqualError(t, err, "jsonmap: value at 'aggregations.hosts.buckets[1].doc_count' is number, expected int")
+
+	// paths are relative to the root
+	var count int
+	assert.Error(t, j.Get("aggregations.hosts.buckets[1].doc_count").Decode(&count))
+	assert.NoError(t, j.Get("aggregations.hosts.buckets[0].doc_count").Decode(&count))
+	assert.Equal(t, 1, count)
+
+	var small struct {
+		V int8 `json:"v"`
+	}
+	assert.EqualError(t, jsonmap.FromString(`{ "v": 300 }`).Decode(&small), "jsonmap: value at 'v' is number, expected int8")
+	assert.EqualError(t, jsonmap.FromString(`{ "tags": "a" }`).Decode(&h), "jsonmap: value at 'tags' is string, expected array")
+	assert.EqualError(t, jsonmap.FromString(`{ "level": "medium" }`).Decode(&h), "jsonmap: unknown level at 'level'")
+	assert.EqualError(t, jsonmap.FromString(`{ "counts": { "x": -1 } }`).Decode(&h), "jsonmap: value at 'counts.x' is number, expected uint16")
+	assert.EqualError(t, jsonmap.FromString(`[1]`).Decode(&h), "jsonmap: value is array, expected jsonmap_test.hit")
+	assert.EqualError(t, jsonmap.FromString(`{ "a.b": { "1x": 1 } }`).Decode(&map[string]map[int]int{}), "jsonmap: invalid int key at 'a\\.b.1x'")
+
+	assert.EqualError(t, j.Decode(h), "jsonmap: can't decode into jsonmap_test.hit, expected a non-nil pointer")
+	assert.EqualError(t, j.Decode(nil), "jsonmap: can't decode into <nil>, expected a non-nil pointer")
+	var ch chan int
+	assert.EqualError(t, jsonmap.FromString(`1`).Decode(&ch), "jsonmap: unsupported type chan int")
+}
+
+func TestDecodePathTags(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"took": 12,
+		"ignored": "x",
+		"hits": {
+			"total": { "value": 2 },
+			"hits": [
+				{ "_id": "1", "_source": { "host": "web-1" } },
+				{ "_id": "2", "_source": { "host": "web-2" } }
+			]
+		},
+		"aggregations": {
+			"total": { "value": 120 },
+			"hosts": { "buckets": [{ "key": "web-1", "doc_count": 80 }, { "key": "web-2", "doc_count": 40 }] }
+		}
+	}`)
+
+	type bucket struct {
+		Key   string `jsonmap:"key"`
+		Count int    `jsonmap:"doc_count"`
+	}
+	var res struct {
+		Took      int      `json:"took"`
+		Hits      int      `jsonmap:"hits.total.value"`
+		Count     int      `jsonmap:"aggregations.total.value"`
+		FirstHost string   `jsonmap:"hits.hits[0]._source.host"`
+		LastID    string   `jsonmap:"hits.hits[-1]._id"`
+		IDs       []string `jsonmap:"hits.hits[*]._id"`
+		Buckets   []bucket `jsonmap:"aggregations.hosts.buckets"`
+		Missing   string   `jsonmap:"aggregations.missing.value"`
+		Ignored   string   `json:"ignored" jsonmap:"-"`
+	}
+	res.Missing = "default"
+	assert.NoError(t, j.Decode(&res))
+	assert.Equal(t, 12, res.Took)
+	assert.Equal(t, 2, res.Hits)
+	assert.Equal(t, 120, res.Count)
+	assert.Equal(t, "web-1", res.FirstHost)
+	assert.Equal(t, "2", res.LastID)
+	assert.Equal(t, []string{"1", "2"}, res.IDs)
+	assert.Equal(t, []bucket{{"web-1", 80}, {"web-2", 40}}, res.Buckets)
+	assert.Equal(t, "default", res.Missing)
+	assert.Empty(t, res.Ignored)
+
+	// paths are relative to the struct object
+	var agg struct {
+		Total int `jsonmap:"total.value"`
+	}
+	assert.NoError(t, j.Get("aggregations").Decode(&agg))
+	assert.Equal(t, 120, agg.Total)
+
+	var bad struct {
+		Total bool `jsonmap:"total.value"`
+	}
+	assert.EqualError(t, j.Get("aggregations").Decode(&bad), "jsonmap: value at 'aggregations.total.value' is number, expected boolean")
+}
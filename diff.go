@@ -0,0 +1,322 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// ChangeType is the type of a Change
+type ChangeType uint8
+
+// Change types
+const (
+	Added ChangeType = iota
+	Removed
+	Modified
+)
+
+var changeTypeNames = [...]string{
+	Added:    "added",
+	Removed:  "removed",
+	Modified: "modified",
+}
+
+// String returns the name of the change type, ie "added"
+func (t ChangeType) String() string {
+	if int(t) < len(changeTypeNames) {
+		return changeTypeNames[t]
+	}
+	return "unknown"
+}
+
+// Change is a difference between two jsons, see Diff
+type Change struct {
+	Type ChangeType
+	Path string // path of the changed value, in the Get syntax
+	From *Json  // previous value, nil json when added
+	To   *Json  // new value, nil json when removed
+}
+
+// String returns a readable change, ie "~ settings.index.number_of_shards: 1 => 3"
+func (c Change) String() string {
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("+ %s: %s", c.Path, c.To.Stringify())
+	case Removed:
+		return fmt.Sprintf("- %s: %s", c.Path, c.From.Stringify())
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", c.Path, c.From.Stringify(), c.To.Stringify())
+	}
+}
+
+// Equal checks if two jsons are deeply equal
+// Numbers are compared by value, ie 1 == 1.0 == json.Number("1")
+func Equal(a *Json, b *Json) bool {
+	return valuesEqual(dataOf(a), dataOf(b))
+}
+
+// Diff returns the changes from a to b
+// Objects are compared key by key and arrays index by index
+func Diff(a *Json, b *Json) []Change {
+	var changes []Change
+	diffValues(dataOf(a), dataOf(b), "", &changes)
+	return changes
+}
+
+// dataOf returns the underlying data of a json, nil for a nil json
+func dataOf(j *Json) interface{} {
+	if j == nil {
+		return nil
+	}
+	j.load()
+	return j.data
+}
+
+// diffValues appends the changes from a to b
+func diffValues(a interface{}, b interface{}, path string, changes *[]Change) {
+	if va, ok := objectOf(a); ok {
+		if vb, ok := objectOf(b); ok {
+			for _, k := range objectKeys(a) {
+				kp := keyPath(path, k)
+				if w, ok := vb[k]; ok {
+					diffValues(va[k], w, kp, changes)
+				} else {
+					*changes = append(*changes, Change{Type: Removed, Path: kp, From: &Json{data: va[k], path: kp}, To: Nil()})
+				}
+			}
+			for _, k := range objectKeys(b) {
+				if _, ok := va[k]; !ok {
+					kp := keyPath(path, k)
+					*changes = append(*changes, Change{Type: Added, Path: kp, From: Nil(), To: &Json{data: vb[k], path: kp}})
+				}
+			}
+			return
+		}
+	}
+
+	if va, ok := a.([]interface{}); ok {
+		if vb, ok := b.([]interface{}); ok {
+			for i := range va {
+				ip := indexPath(path, i)
+				if i < len(vb) {
+					diffValues(va[i], vb[i], ip, changes)
+				} else {
+					*changes = append(*changes, Change{Type: Removed, Path: ip, From: &Json{data: va[i], path: ip}, To: Nil()})
+				}
+			}
+			for i := len(va); i < len(vb); i++ {
+				ip := indexPath(path, i)
+				*changes = append(*changes, Change{Type: Added, Path: ip, From: Nil(), To: &Json{data: vb[i], path: ip}})
+			}
+			return
+		}
+	}
+
+	if !valuesEqual(a, b) {
+		*changes = append(*changes, Change{Type: Modified, Path: path, From: &Json{data: a, path: path}, To: &Json{data: b, path: path}})
+	}
+}
+
+// valuesEqual checks the equality of two json values, numbers are compared by value, see numbersEqual
+// Other Go values (ie time.Time) are deeply compared
+func valuesEqual(a, b interface{}) bool {
+	if kindOf(a) == NumberKind {
+		return kindOf(b) == NumberKind && numbersEqual(a, b)
+	}
+
+	switch va := a.(type) {
+	case nil:
+		return b == nil
+	case bool:
+		vb, ok := b.(bool)
+		return ok && va == vb
+	case string:
+		vb, ok := b.(string)
+		return ok && va == vb
+	case []interface{}:
+		vb, ok := b.([]interface{})
+		if !ok || len(va) != len(vb) {
+			return false
+		}
+		for i := range va {
+			if !valuesEqual(va[i], vb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}, *orderedObject:
+		oa, _ := objectOf(va)
+		ob, ok := objectOf(b)
+		if !ok || len(oa) != len(ob) {
+			return false
+		}
+		for k, v := range oa {
+			w, ok := ob[k]
+			if !ok || !valuesEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// numbersEqual compares two numbers exactly, see compareNumbers
+func numbersEqual(a, b interface{}) bool {
+	c, ok := compareNumbers(a, b)
+	return ok && c == 0
+}
+
+// compareNumbers compares two numbers exactly, ie json.Numbers above 2^53 are not rounded
+// Floats are compared as float64, integers without allocating, other numbers as rationals.
+// Returns -1, 0 or +1, false if a or b is not a number
+func compareNumbers(a, b interface{}) (int, bool) {
+	if isFloat(a) && isFloat(b) {
+		fa, _ := numberOf(a)
+		fb, _ := numberOf(b)
+		switch {
+		case fa < fb:
+			return -1, true
+		case fa > fb:
+			return 1, true
+		}
+		return 0, true
+	}
+	if na, ok := a.(json.Number); ok {
+		if nb, ok := b.(json.Number); ok && na == nb {
+			return 0, true
+		}
+	}
+	if ia, ok := integerOf(a); ok {
+		if ib, ok := integerOf(b); ok {
+			return ia.cmp(ib), true
+		}
+	}
+	ra, ok := ratOf(a)
+	if !ok {
+		return 0, false
+	}
+	rb, ok := ratOf(b)
+	if !ok {
+		return 0, false
+	}
+	return ra.Cmp(rb), true
+}
+
+// integer is an integer number of any Go type, by its sign and its absolute value
+type integer struct {
+	neg bool
+	abs uint64
+}
+
+// integerOf converts an integer, or a json.Number written as an integer, without allocating
+func integerOf(v interface{}) (integer, bool) {
+	var i int64
+	switch cv := v.(type) {
+	case int:
+		i = int64(cv)
+	case int8:
+		i = int64(cv)
+	case int16:
+		i = int64(cv)
+	case int32:
+		i = int64(cv)
+	case int64:
+		i = cv
+	case uint:
+		return integer{abs: uint64(cv)}, true
+	case uint8:
+		return integer{abs: uint64(cv)}, true
+	case uint16:
+		return integer{abs: uint64(cv)}, true
+	case uint32:
+		return integer{abs: uint64(cv)}, true
+	case uint64:
+		return integer{abs: cv}, true
+	case json.Number:
+		if !isInteger(string(cv)) {
+			return integer{}, false
+		}
+		if cv[0] != '-' {
+			u, err := strconv.ParseUint(string(cv), 10, 64)
+			return integer{abs: u}, err == nil
+		}
+		var err error
+		if i, err = strconv.ParseInt(string(cv), 10, 64); err != nil {
+			return integer{}, false
+		}
+	default:
+		return integer{}, false
+	}
+	if i < 0 {
+		return integer{neg: true, abs: uint64(-(i + 1)) + 1}, true
+	}
+	return integer{abs: uint64(i)}, true
+}
+
+// isInteger checks if s is only digits, optionally after a minus
+func isInteger(s string) bool {
+	if len(s) > 0 && s[0] == '-' {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// cmp compares two integers, returns -1, 0 or +1
+func (a integer) cmp(b integer) int {
+	switch {
+	case a.neg != b.neg:
+		if a.neg {
+			return -1
+		}
+		return 1
+	case a.abs == b.abs:
+		return 0
+	case (a.abs < b.abs) != a.neg:
+		return -1
+	}
+	return 1
+}
+
+// isFloat checks if v is a float64 or a float32
+func isFloat(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32:
+		return true
+	}
+	return false
+}
+
+// ratOf converts a number to an exact rational, floats are converted from their shortest representation
+func ratOf(v interface{}) (*big.Rat, bool) {
+	var text string
+	switch cv := v.(type) {
+	case json.Number:
+		text = cv.String()
+	case float64:
+		text = strconv.FormatFloat(cv, 'g', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(cv), 'g', -1, 32)
+	default:
+		if i, ok := integerOf(cv); ok {
+			r := new(big.Rat).SetUint64(i.abs)
+			if i.neg {
+				r.Neg(r)
+			}
+			return r, true
+		}
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
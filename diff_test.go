@@ -0,0 +1,134 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqual(t *testing.T) {
+	a := jsonmap.FromString(`{ "a": 1, "b": [true, "x", null, { "c": 1.5 }] }`)
+	b := jsonmap.FromString(`{ "b": [true, "x", null, { "c": 1.50 }], "a": 1.0 }`)
+	assert.True(t, jsonmap.Equal(a, b))
+	assert.True(t, jsonmap.Equal(a, a.Clone()))
+
+	b.Set("b[3].c", 2)
+	assert.False(t, jsonmap.Equal(a, b))
+	assert.False(t, jsonmap.Equal(a, jsonmap.FromString(`{ "a": 1 }`)))
+	assert.False(t, jsonmap.Equal(jsonmap.FromString(`[1, 2]`), jsonmap.FromString(`[1, 2, 3]`)))
+	assert.False(t, jsonmap.Equal(jsonmap.FromString(`"1"`), jsonmap.FromString(`1`)))
+
+	// numbers
+	n := jsonmap.New()
+	n.Set("int", 1)
+	n.Set("float", float64(1))
+	n.Set("number", json.Number("1"))
+	assert.True(t, jsonmap.Equal(n.Get("int"), n.Get("float")))
+	assert.True(t, jsonmap.Equal(n.Get("int"), n.Get("number")))
+	assert.True(t, jsonmap.Equal(n.Get("number"), jsonmap.FromString(`1e0`)))
+
+	// big numbers are compared exactly
+	big1, _ := jsonmap.ParseString(`{ "id": 9007199254740993 }`, jsonmap.UseNumber(true))
+	big2, _ := jsonmap.ParseString(`{ "id": 9007199254740992 }`, jsonmap.UseNumber(true))
+	assert.False(t, jsonmap.Equal(big1, big2))
+	assert.True(t, jsonmap.Equal(big1, big1.Clone()))
+	assert.Len(t, jsonmap.Diff(big1, big2), 1)
+	assert.Equal(t, `[{"op":"replace","path":"/id","value":9007199254740992}]`, jsonmap.CreatePatch(big1, big2).Stringify())
+	assert.Equal(t, `{"id":9007199254740992}`, jsonmap.CreateMergePatch(big1, big2).Stringify())
+	n.Set("big", uint64(9007199254740993))
+	assert.True(t, jsonmap.Equal(n.Get("big"), big1.Get("id")))
+	assert.False(t, jsonmap.Equal(n.Get("big"), big2.Get("id")))
+	for _, tc := range []struct {
+		a, b  interface{}
+		equal bool
+	}{
+		{int64(math.MinInt64), json.Number("-9223372036854775808"), true},
+		{int8(-1), json.Number("-1"), true},
+		{int8(-1), uint8(255), false},
+		{uint64(math.MaxUint64), json.Number("18446744073709551615"), true},
+		{uint64(math.MaxUint64), json.Number("18446744073709551616"), false},
+		{json.Number("-0"), 0, true},
+		{json.Number("10"), json.Number("1e1"), true},
+		{json.Number("2"), float32(2), true},
+	} {
+		a, b := jsonmap.New(), jsonmap.New()
+		a.Set("v", tc.a)
+		b.Set("v", tc.b)
+		assert.Equal(t, tc.equal, jsonmap.Equal(a, b), "%v == %v", tc.a, tc.b)
+	}
+
+	// other values
+	now := time.Now()
+	n.Set("time", now)
+	assert.True(t, jsonmap.Equal(n.Get("time"), n.Clone().Get("time")))
+
+	// nils
+	assert.True(t, jsonmap.Equal(nil, jsonmap.Nil()))
+	assert.False(t, jsonmap.Equal(nil, jsonmap.New()))
+}
+
+func TestDiff(t *testing.T) {
+	a := jsonmap.FromString(`{
+		"mappings": {
+			"properties": {
+				"title": { "type": "text" },
+				"date": { "type": "date" },
+				"tags": { "type": "keyword", "fields": ["raw", "lower"] }
+			}
+		}
+	}`)
+	b := jsonmap.FromString(`{
+		"mappings": {
+			"properties": {
+				"title": { "type": "keyword" },
+				"date": { "type": "date" },
+				"tags": { "type": "keyword", "fields": ["raw"] },
+				"host.name": { "type": "keyword" }
+			}
+		}
+	}`)
+
+	assert.Empty(t, jsonmap.Diff(a, a.Clone()))
+
+	changes := jsonmap.Diff(a, b)
+	assert.Len(t, changes, 3)
+
+	assert.Equal(t, jsonmap.Removed, changes[0].Type)
+	assert.Equal(t, "mappings.properties.tags.fields[1]", changes[0].Path)
+	assert.Equal(t, "lower", changes[0].From.AsString())
+	assert.True(t, changes[0].To.IsNil())
+
+	assert.Equal(t, jsonmap.Modified, changes[1].Type)
+	assert.Equal(t, "mappings.properties.title.type", changes[1].Path)
+	assert.Equal(t, "text", changes[1].From.AsString())
+	assert.Equal(t, "keyword", changes[1].To.AsString())
+
+	assert.Equal(t, jsonmap.Added, changes[2].Type)
+	assert.Equal(t, `mappings.properties.host\.name`, changes[2].Path)
+	assert.Equal(t, "keyword", changes[2].To.Get("type").AsString())
+	assert.True(t, changes[2].From.IsNil())
+
+	// paths are valid Get paths
+	for _, c := range changes {
+		assert.Equal(t, c.From.Stringify(), a.Get(c.Path).Stringify())
+		assert.Equal(t, c.To.Stringify(), b.Get(c.Path).Stringify())
+	}
+
+	assert.Equal(t, `- mappings.properties.tags.fields[1]: "lower"`, changes[0].String())
+	assert.Equal(t, `~ mappings.properties.title.type: "text" => "keyword"`, changes[1].String())
+	assert.Equal(t, `+ mappings.properties.host\.name: {"type":"keyword"}`, changes[2].String())
+	assert.Equal(t, "modified", changes[1].Type.String())
+
+	// kinds
+	changes = jsonmap.Diff(jsonmap.FromString(`{ "a": [1] }`), jsonmap.FromString(`{ "a": { "0": 1 } }`))
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "~ a: [1] => {\"0\":1}", changes[0].String())
+
+	changes = jsonmap.Diff(jsonmap.FromString(`[1, 2]`), jsonmap.FromString(`[1.0, 2, 3]`))
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "+ [2]: 3", changes[0].String())
+}
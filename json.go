@@ -4,12 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Json is our wrapper to an unmarshalled json
 type Json struct {
-	data interface{}
+	data   interface{}
+	path   string    // path used to reach this json, see Path()
+	coerce bool      // coerce mode, see Coerce()
+	lazy   *lazyData // undecoded input, see FromBytesLazy
 }
 
 // A Jsonizer can converts to a json
@@ -36,17 +42,21 @@ func New() *Json {
 
 // Nil creates an nil Json
 func Nil() *Json {
-	return &Json{nil}
+	return &Json{data: nil}
 }
 
 // FromBytes to creates a Json from bytes
+// Returns a nil Json if bytes are not a valid json, use Parse to get the error
 func FromBytes(bytes []byte) *Json {
-	j := new(Json)
-	j.UnmarshalJSON(bytes)
+	j, err := Parse(bytes)
+	if err != nil {
+		return Nil()
+	}
 	return j
 }
 
 // FromString to creates a Json from a string
+// Returns a nil Json if the string is not a valid json, use ParseString to get the error
 func FromString(str string) *Json {
 	return FromBytes([]byte(str))
 }
@@ -59,18 +69,28 @@ func FromMap(m map[string]interface{}) *Json {
 }
 
 // Stringify formats current node to a json string
-func (j *Json) Stringify() string {
-	return string(j.Bytes())
+// Example : Stringify(Canonical(true)) for a canonical json
+func (j *Json) Stringify(opt ...StringifyOption) string {
+	return string(j.Bytes(opt...))
 }
 
 // Bytes return json bytes
-func (j *Json) Bytes() []byte {
+func (j *Json) Bytes(opt ...StringifyOption) []byte {
+	j.load()
+	if newStringifyOptions(opt...).Canonical {
+		bytes, err := canonicalize(j.data)
+		if err != nil {
+			return []byte("null")
+		}
+		return bytes
+	}
 	bytes, _ := j.MarshalJSON()
 	return bytes
 }
 
 // MarshalJSON implements marshaler interface from encoding/json encode.go
 func (j *Json) MarshalJSON() ([]byte, error) {
+	j.load()
 	if j.IsNil() {
 		return []byte("null"), nil
 	}
@@ -86,29 +106,50 @@ func (j *Json) UnmarshalJSON(data []byte) error {
 	if j == nil {
 		return errors.New("unmarshal JSON on a nil pointer")
 	}
-	return json.Unmarshal(data, &j.data)
+	v, err := decode(data, defaultParseOptions)
+	if err != nil {
+		return err
+	}
+	j.data, j.lazy = v, nil
+	return nil
 }
 
 // Data get uncasted data
+// Ordered objects, see Ordered, are returned as map[string]interface{} : their values are copied,
+// so modifying them doesn't modify the json
 func (j *Json) Data() interface{} {
+	j.load()
+	if isOrdered(j.data) {
+		return plainValue(j.data, 0)
+	}
 	return j.data
 }
 
+// Path returns the path used to reach the current json from its root
+// through Get, ForEach or Values. Empty for a root json
+func (j *Json) Path() string {
+	return j.path
+}
+
 // IsNil to check if the current Json is nil
 func (j *Json) IsNil() bool {
+	j.load()
 	return j.data == nil
 }
 
 // IsObject to know if the current Json is an object
 func (j *Json) IsObject() bool {
-	_, ok := (j.data).(map[string]interface{})
+	j.load()
+	_, ok := objectOf(j.data)
 	return ok
 }
 
 // AsObject casts underlying to object (map[string]interface{})
+// Returns the members of ordered objects, use Set and Unset to keep their order
 // Returns nil if not an object
 func (j *Json) AsObject() map[string]interface{} {
-	if casted, ok := (j.data).(map[string]interface{}); ok {
+	j.load()
+	if casted, ok := objectOf(j.data); ok {
 		return casted
 	}
 	return nil
@@ -116,6 +157,7 @@ func (j *Json) AsObject() map[string]interface{} {
 
 // IsArray to check if the current Json is an array
 func (j *Json) IsArray() bool {
+	j.load()
 	_, ok := (j.data).([]interface{})
 	return ok
 }
@@ -123,6 +165,7 @@ func (j *Json) IsArray() bool {
 // AsArray casts underlying to array ([]interface{})
 // Returns nil if not an array
 func (j *Json) AsArray() []interface{} {
+	j.load()
 	if casted, ok := (j.data).([]interface{}); ok {
 		return casted
 	}
@@ -137,146 +180,464 @@ func (j *Json) IsValue() bool {
 // AsString casts underlying to string
 // Returns an empty string if not a string
 func (j *Json) AsString() string {
-	if casted, ok := (j.data).(string); ok {
-		return casted
+	s, _ := j.asString()
+	return s
+}
+
+// AsStringOr casts underlying to string
+// Returns def if not a string
+func (j *Json) AsStringOr(def string) string {
+	if s, ok := j.asString(); ok {
+		return s
 	}
-	return ""
+	return def
+}
+
+func (j *Json) asString() (string, bool) {
+	casted, ok := j.as(StringKind).(string)
+	return casted, ok
 }
 
 // AsBool casts underlying to boolean
 // Returns false if not a boolean
 func (j *Json) AsBool() bool {
-	if casted, ok := (j.data).(bool); ok {
-		return casted
+	b, _ := j.asBool()
+	return b
+}
+
+// AsBoolOr casts underlying to boolean
+// Returns def if not a boolean
+func (j *Json) AsBoolOr(def bool) bool {
+	if b, ok := j.asBool(); ok {
+		return b
 	}
-	return false
+	return def
+}
+
+func (j *Json) asBool() (bool, bool) {
+	casted, ok := j.as(BoolKind).(bool)
+	return casted, ok
 }
 
 // AsInt casts underlying to int64
 // Returns 0 if not an int
 func (j *Json) AsInt() int64 {
-	switch j.data.(type) {
+	i, _ := j.asInt()
+	return i
+}
+
+// AsIntOr casts underlying to int64
+// Returns def if not an int
+func (j *Json) AsIntOr(def int64) int64 {
+	if i, ok := j.asInt(); ok {
+		return i
+	}
+	return def
+}
+
+func (j *Json) asInt() (int64, bool) {
+	data := j.as(NumberKind)
+	switch data.(type) {
 	case json.Number:
-		if i, err := (j.data).(json.Number).Int64(); err != nil {
-			return i
+		if i, err := data.(json.Number).Int64(); err == nil {
+			return i, true
+		}
+		if f, err := data.(json.Number).Float64(); err == nil {
+			return int64(f), true
 		}
-		return 0
+		return 0, false
 	case float32, float64:
-		return int64(reflect.ValueOf(j.data).Float())
+		return int64(reflect.ValueOf(data).Float()), true
 	case int, int8, int16, int32, int64:
-		return reflect.ValueOf(j.data).Int()
+		return reflect.ValueOf(data).Int(), true
 	case uint, uint8, uint16, uint32, uint64:
-		return int64(reflect.ValueOf(j.data).Uint())
+		return int64(reflect.ValueOf(data).Uint()), true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
 // AsUint casts underlying to uint64
 // Returns 0 if not an int
 func (j *Json) AsUint() uint64 {
-	switch j.data.(type) {
+	u, _ := j.asUint()
+	return u
+}
+
+// AsUintOr casts underlying to uint64
+// Returns def if not an int
+func (j *Json) AsUintOr(def uint64) uint64 {
+	if u, ok := j.asUint(); ok {
+		return u
+	}
+	return def
+}
+
+func (j *Json) asUint() (uint64, bool) {
+	data := j.as(NumberKind)
+	switch data.(type) {
 	case json.Number:
-		if u, err := strconv.ParseUint(j.data.(json.Number).String(), 10, 64); err != nil {
-			return u
+		if u, err := strconv.ParseUint(data.(json.Number).String(), 10, 64); err == nil {
+			return u, true
 		}
-		return 0
+		if f, err := data.(json.Number).Float64(); err == nil {
+			return uint64(f), true
+		}
+		return 0, false
 	case float32, float64:
-		return uint64(reflect.ValueOf(j.data).Float())
+		return uint64(reflect.ValueOf(data).Float()), true
 	case int, int8, int16, int32, int64:
-		return uint64(reflect.ValueOf(j.data).Int())
+		return uint64(reflect.ValueOf(data).Int()), true
 	case uint, uint8, uint16, uint32, uint64:
-		return reflect.ValueOf(j.data).Uint()
+		return reflect.ValueOf(data).Uint(), true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
 // AsFloat casts underlying to float64
 // Returns 0 if not a float
 func (j *Json) AsFloat() float64 {
-	switch j.data.(type) {
+	f, _ := j.asFloat()
+	return f
+}
+
+// AsFloatOr casts underlying to float64
+// Returns def if not a float
+func (j *Json) AsFloatOr(def float64) float64 {
+	if f, ok := j.asFloat(); ok {
+		return f
+	}
+	return def
+}
+
+func (j *Json) asFloat() (float64, bool) {
+	data := j.as(NumberKind)
+	switch data.(type) {
 	case json.Number:
-		if f, err := (j.data).(json.Number).Float64(); err != nil {
-			return f
+		if f, err := data.(json.Number).Float64(); err == nil {
+			return f, true
 		}
-		return 0
+		return 0, false
 	case float32, float64:
-		return reflect.ValueOf(j.data).Float()
+		return reflect.ValueOf(data).Float(), true
 	case int, int8, int16, int32, int64:
-		return float64(reflect.ValueOf(j.data).Int())
+		return float64(reflect.ValueOf(data).Int()), true
 	case uint, uint8, uint16, uint32, uint64:
-		return float64(reflect.ValueOf(j.data).Uint())
+		return float64(reflect.ValueOf(data).Uint()), true
 	default:
-		return 0
+		return 0, false
 	}
 }
 
-// Get gets the value at path of object. If not found returns Nils() value
-func (j *Json) Get(path string) *Json {
-	keys := createPath(path)
-	curr := j
-	for _, k := range keys {
+// AsTime casts underlying to time.Time
+// Handles time.Time values and RFC3339 strings, and epoch milliseconds in coerce mode
+// Returns the zero time if not a time
+func (j *Json) AsTime() time.Time {
+	t, _ := j.asTime()
+	return t
+}
 
-		// Get  as object
-		if o := curr.AsObject(); o != nil {
-			val, ok := o[k]
-			if !ok {
-				return Nil()
-			}
-			curr = &Json{val}
-			continue
-		}
+// AsTimeOr casts underlying to time.Time
+// Returns def if not a time
+func (j *Json) AsTimeOr(def time.Time) time.Time {
+	if t, ok := j.asTime(); ok {
+		return t
+	}
+	return def
+}
 
-		// Get as array
-		if a := curr.AsArray(); a != nil {
-			// Must be an int
-			idx, e := strconv.Atoi(k)
-			if e != nil || idx < 0 || idx >= len(a) {
-				return Nil()
-			}
-			curr = &Json{a[idx]}
-			continue
+func (j *Json) asTime() (time.Time, bool) {
+	j.load()
+	switch v := j.data.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339, v)
+		return t, err == nil
+	}
+	if j.coerce {
+		if ms, ok := j.asInt(); ok {
+			return time.Unix(0, ms*int64(time.Millisecond)).UTC(), true
 		}
+	}
+	return time.Time{}, false
+}
 
-		// Not found
-		return Nil()
+// Get gets the value at path of object. If not found returns Nils() value
+// Negative array indexes start from the end, ie "items[-1]" is the last item.
+// With wildcards, ie "items[*].name", returns the first match, see GetAll
+// Malformed paths are accepted leniently, see MustGet
+func (j *Json) Get(path string) *Json {
+	return j.get(path, pathKeys(path))
+}
+
+// MustGet is like Get but panics if path is malformed, see ParsePath
+func (j *Json) MustGet(path string) *Json {
+	return MustCompile(path).Get(j)
+}
+
+// get gets the value at keys, parsed from path
+func (j *Json) get(path string, keys []pathKey) *Json {
+	res := &Json{path: joinPath(j.path, path), coerce: j.coerce}
+	if l, ok := j.lazyGet(path, keys); ok {
+		res.lazy = l
+		return res
+	}
+	j.load()
+	if val, ok := getValue(j.data, keys); ok {
+		res.data = val
 	}
+	return res
+}
 
-	return curr
+// GetAll gets all the values matching path, which can contain wildcards
+// Example : GetAll("hits.hits[*]._source.name") returns the name of each hit
+// Object members are matched in key order. Each Json has its Path() set to its location
+func (j *Json) GetAll(path string) []*Json {
+	j.load()
+	var res []*Json
+	walkValues(j.data, pathKeys(path), location{base: j.path}, func(v interface{}, at location) bool {
+		res = append(res, &Json{data: v, path: at.path(), coerce: j.coerce})
+		return true
+	})
+	return res
 }
 
 // Has checks if path is a direct property of object.
+// With wildcards, checks if at least one match is not nil
 func (j *Json) Has(path string) bool {
-	o := j.Get(path)
-	return !o.IsNil()
+	return j.has(pathKeys(path))
+}
+
+// has checks if the value at keys is not nil
+func (j *Json) has(keys []pathKey) bool {
+	if raw, ok := j.lazyValue(keys); ok {
+		return raw != nil && string(raw) != "null"
+	}
+	j.load()
+	if !hasWildcard(keys) {
+		v, ok := getValue(j.data, keys)
+		return ok && v != nil
+	}
+	has := false
+	walkValues(j.data, keys, location{}, func(v interface{}, at location) bool {
+		has = v != nil
+		return !has
+	})
+	return has
 }
 
 // Set sets the value at path of object. If a portion of path doesn't exist, it's created.
 // Arrays are created for missing index properties while objects are created for all other missing properties
+// An index past the end of an array pads the array with nulls, ie "tags[3]", with at most 10000 nulls, and an empty index appends, ie "tags[]"
+// With wildcards, ie "items[*].visible", sets the value in all the existing matches which are objects or arrays
+// A key named "*" is escaped, ie "\*", see EscapePath
 func (j *Json) Set(path string, value interface{}) bool {
-	keys := createPath(path)
-	lastIndex := len(keys) - 1
+	return j.set(pathKeys(path), value)
+}
+
+// TrySet is like Set but returns a *PathError if path is malformed, see ParsePath, or if the value can't be set
+func (j *Json) TrySet(path string, value interface{}) error {
+	p, err := ParsePath(path)
+	if err != nil {
+		return err
+	}
+	if !p.Set(j, value) {
+		return &PathError{path, -1, "can't set the value"}
+	}
+	return nil
+}
+
+// set sets the value at keys
+func (j *Json) set(keys []pathKey, value interface{}) bool {
+	j.modify()
+	data, ok := setValue(j.data, keys, toData(value))
+	if ok {
+		j.data = data
+	}
+	return ok
+}
+
+// Append appends values to the array at path. The array is created if path doesn't exist
+// Returns false if path is not an array or contains wildcards
+func (j *Json) Append(path string, values ...interface{}) bool {
+	j.modify()
+	keys := pathKeys(path)
+	if hasWildcard(keys) {
+		return false
+	}
+
+	var arr []interface{}
+	if curr, ok := getValue(j.data, keys); ok && curr != nil {
+		if arr, ok = curr.([]interface{}); !ok {
+			return false
+		}
+	}
+	for _, v := range values {
+		arr = append(arr, toData(v))
+	}
+	if arr == nil {
+		arr = []interface{}{}
+	}
+	return j.Set(path, arr)
+}
+
+// Insert inserts value at index idx of the array at path
+// Negative indexes start from the end, and idx equals to the length of the array appends value
+// Returns false if path is not an array, contains wildcards or if idx is out of range
+func (j *Json) Insert(path string, idx int, value interface{}) bool {
+	j.modify()
+	keys := pathKeys(path)
+	if hasWildcard(keys) {
+		return false
+	}
+
+	curr, _ := getValue(j.data, keys)
+	arr, ok := curr.([]interface{})
+	if !ok {
+		return false
+	}
+	if idx < 0 {
+		idx += len(arr)
+	}
+	if idx < 0 || idx > len(arr) {
+		return false
+	}
+
+	arr = append(arr, nil)
+	copy(arr[idx+1:], arr[idx:])
+	arr[idx] = toData(value)
+	data, ok := setValue(j.data, keys, arr)
+	if ok {
+		j.data = data
+	}
+	return ok
+}
+
+// Unset deletes the value
+// With wildcards, ie "items[*].name", deletes all the matches
+func (j *Json) Unset(path string) bool {
+	return j.unset(pathKeys(path))
+}
+
+// unset deletes the value at keys
+func (j *Json) unset(keys []pathKey) bool {
+	j.modify()
+	if len(keys) == 0 {
+		return false
+	}
+	data, ok := unsetValue(j.data, keys)
+	if ok {
+		j.data = data
+	}
+	return ok
+}
+
+// UnsetAll deletes the values at paths, which can contain wildcards
+// Paths are resolved before any deletion, so "items[0]" and "items[1]" delete the two first items
+// Returns the number of deleted values
+func (j *Json) UnsetAll(paths ...string) int {
+	j.modify()
+	var locations [][]pathKey
+	for _, path := range paths {
+		walkValues(j.data, pathKeys(path), location{}, func(v interface{}, at location) bool {
+			if len(at.steps) > 0 {
+				locations = append(locations, at.keys())
+			}
+			return true
+		})
+	}
+
+	// Delete the last items first to keep the other indexes valid
+	sort.Slice(locations, func(a, b int) bool {
+		return compareKeys(locations[a], locations[b]) > 0
+	})
+
+	count := 0
+	for i, at := range locations {
+		if i > 0 && compareKeys(at, locations[i-1]) == 0 {
+			continue
+		}
+		if data, ok := unsetValue(j.data, at); ok {
+			j.data = data
+			count++
+		}
+	}
+	return count
+}
+
+// compareKeys compares concrete keys, array indexes are compared as numbers
+func compareKeys(a, b []pathKey) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		ka, kb := a[i], b[i]
+		switch {
+		case ka.isIndex && kb.isIndex && ka.index != kb.index:
+			if ka.index < kb.index {
+				return -1
+			}
+			return 1
+		case ka.name != kb.name:
+			return strings.Compare(ka.name, kb.name)
+		}
+	}
+	return len(a) - len(b)
+}
+
+// Compact removes the null items of the array at path, which can contain wildcards
+// Example : Compact("items[*].tags") removes the null tags of each item
+// Returns the number of removed items
+func (j *Json) Compact(path string) int {
+	j.modify()
+	type compacted struct {
+		keys  []pathKey
+		items []interface{}
+	}
+	var arrays []compacted
+	count := 0
+
+	walkValues(j.data, pathKeys(path), location{}, func(v interface{}, at location) bool {
+		if a, ok := v.([]interface{}); ok {
+			items := make([]interface{}, 0, len(a))
+			for _, item := range a {
+				if item != nil {
+					items = append(items, item)
+				}
+			}
+			if len(items) < len(a) {
+				count += len(a) - len(items)
+				arrays = append(arrays, compacted{at.keys(), items})
+			}
+		}
+		return true
+	})
 
-	// Pick value
-	var newValue interface{}
+	for _, a := range arrays {
+		if data, ok := setValue(j.data, a.keys, a.items); ok {
+			j.data = data
+		}
+	}
+	return count
+}
 
+// toData converts a value to set into an uncasted data
+func toData(value interface{}) interface{} {
 	switch cv := value.(type) {
 	case Jsonizer:
-		newValue = jsonize(cv).data
+		return dataOf(jsonize(cv))
 
 	case *Json:
-		newValue = cv.data
+		return dataOf(cv)
 
 	case []*Json:
 		datas := make([]interface{}, 0, len(cv))
 		for _, item := range cv {
-			datas = append(datas, item.data)
+			datas = append(datas, dataOf(item))
 		}
-		newValue = datas
+		return datas
 
 	case []interface{}:
-		newValue = cv
+		return cv
 
 	default:
 		rv := reflect.ValueOf(value)
@@ -292,123 +653,395 @@ func (j *Json) Set(path string, value interface{}) bool {
 				}
 
 				if jsonizer, ok := val.Interface().(Jsonizer); ok {
-					datas[i] = jsonize(jsonizer).data
+					datas[i] = dataOf(jsonize(jsonizer))
 					continue
 				}
 
 				datas[i] = val.Interface()
 			}
-			newValue = datas
+			return datas
+		}
+		return value
+	}
+}
+
+// getValue gets the first value matching keys in data
+// Keys without wildcards are resolved without walking, to avoid building the locations
+func getValue(data interface{}, keys []pathKey) (interface{}, bool) {
+	if !hasWildcard(keys) {
+		for _, k := range keys {
+			if o, ok := objectOf(data); ok {
+				if data, ok = o[k.name]; !ok {
+					return nil, false
+				}
+				continue
+			}
+			a, ok := data.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			idx, ok := arrayIndex(k, len(a))
+			if !ok {
+				return nil, false
+			}
+			data = a[idx]
+		}
+		return data, true
+	}
+
+	var res interface{}
+	found := false
+	walkValues(data, keys, location{}, func(v interface{}, at location) bool {
+		res, found = v, true
+		return false
+	})
+	return res, found
+}
+
+// location is the concrete location of a value in a json
+// Its steps share a stack during a walk, and are only valid in the callback : see keys and path
+type location struct {
+	base  string    // path of the walked json, see Path()
+	steps []pathKey // without wildcards, the names of indexes are not set
+}
+
+// push returns the location of a child, the step overwrites the stack after l
+func (l location) push(k pathKey) location {
+	steps := l.steps[:len(l.steps)+1]
+	steps[len(l.steps)] = k
+	return location{base: l.base, steps: steps}
+}
+
+// keys returns a copy of the keys of the location
+func (l location) keys() []pathKey {
+	keys := make([]pathKey, len(l.steps))
+	for i, k := range l.steps {
+		if k.isIndex {
+			k.name = strconv.Itoa(k.index)
+		}
+		keys[i] = k
+	}
+	return keys
+}
+
+// path returns the path of the location, see Path()
+func (l location) path() string {
+	path := l.base
+	for _, k := range l.steps {
+		if k.isIndex {
+			path = indexPath(path, k.index)
 		} else {
-			newValue = value
+			path = keyPath(path, k.name)
 		}
 	}
+	return path
+}
 
-	if lastIndex == -1 {
-		j.data = newValue
-		return true
+// walkValues calls fn for each value matching keys in data, with its location.
+// Object members are visited in key order, iteration stops when fn returns false.
+// Returns false if the iteration was stopped
+func walkValues(data interface{}, keys []pathKey, at location, fn func(v interface{}, at location) bool) bool {
+	if cap(at.steps) < len(at.steps)+len(keys) {
+		steps := make([]pathKey, len(at.steps), len(at.steps)+len(keys))
+		copy(steps, at.steps)
+		at.steps = steps
 	}
+	return walk(data, keys, at, fn)
+}
 
-	curr := j
-	for i, k := range keys {
-		// Get as object
-		if o := curr.AsObject(); o != nil {
-			// Assign value
-			if i == lastIndex {
-				o[k] = newValue
-				return true
+// walk walks values for walkValues, the stack of at has room for keys
+func walk(data interface{}, keys []pathKey, at location, fn func(v interface{}, at location) bool) bool {
+	if len(keys) == 0 {
+		return fn(data, at)
+	}
+	k := keys[0]
+
+	// Get  as object
+	if o, ok := objectOf(data); ok {
+		if k.wildcard {
+			for _, name := range objectKeys(data) {
+				if !walk(o[name], keys[1:], at.push(pathKey{name: name}), fn) {
+					return false
+				}
 			}
+			return true
+		}
+		if val, ok := o[k.name]; ok {
+			return walk(val, keys[1:], at.push(pathKey{name: k.name}), fn)
+		}
+		return true
+	}
 
-			if _, ok := o[k]; !ok {
-				o[k] = make(map[string]interface{})
+	// Get as array
+	if a, ok := data.([]interface{}); ok {
+		if k.wildcard {
+			for i, val := range a {
+				if !walk(val, keys[1:], at.push(pathKey{index: i, isIndex: true, bracket: true}), fn) {
+					return false
+				}
 			}
-			curr = &Json{o[k]}
-			continue
+			return true
+		}
+		if idx, ok := arrayIndex(k, len(a)); ok {
+			return walk(a[idx], keys[1:], at.push(pathKey{index: idx, isIndex: true, bracket: true}), fn)
 		}
+	}
+
+	// Not found
+	return true
+}
+
+// setValue sets value at keys in data, missing objects are created
+// Missing objects are ordered if data is ordered, see Ordered
+// Returns the updated data, which must be written back in the parent
+func setValue(data interface{}, keys []pathKey, value interface{}) (interface{}, bool) {
+	return setter{ordered: isOrdered(data)}.set(data, keys, value)
+}
+
+// settable checks if a wildcard match can be set at keys : matches are replaced,
+// but only their objects and arrays are set inside, scalars are kept
+func settable(match interface{}, keys []pathKey) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	kind := kindOf(match)
+	return kind == ObjectKind || kind == ArrayKind
+}
+
+// maxPadding is the max number of nulls padding an array when setting an index past its end,
+// to not allocate huge arrays from untrusted indexes
+const maxPadding = 10000
+
+// setter sets values, see setValue
+type setter struct {
+	ordered bool // creates ordered objects
+}
+
+func (s setter) set(data interface{}, keys []pathKey, value interface{}) (interface{}, bool) {
+	if len(keys) == 0 {
+		return value, true
+	}
+	k := keys[0]
 
-		// Get as array
-		if a := curr.AsArray(); a != nil {
-			// Must be an int
-			idx, e := strconv.Atoi(k)
-			if e != nil || idx < 0 || idx >= len(a) {
-				return false
+	// Set in object
+	if o, ok := objectOf(data); ok {
+		if k.append && k.bracket {
+			return data, false
+		}
+		if k.wildcard {
+			set := false
+			for _, name := range objectKeys(data) {
+				if !settable(o[name], keys[1:]) {
+					continue
+				}
+				if child, ok := s.set(o[name], keys[1:], value); ok {
+					o[name] = child
+					set = true
+				}
 			}
+			return data, set
+		}
+		child, ok := s.set(o[k.name], keys[1:], value)
+		if ok {
+			setMember(data, k.name, child)
+		}
+		return data, ok
+	}
 
-			// Assign value
-			if i == lastIndex {
-				a[idx] = newValue
-				return true
+	// Set in array
+	if a, ok := data.([]interface{}); ok {
+		if k.wildcard {
+			set := false
+			for i, val := range a {
+				if !settable(val, keys[1:]) {
+					continue
+				}
+				if child, ok := s.set(val, keys[1:], value); ok {
+					a[i] = child
+					set = true
+				}
 			}
+			return a, set
+		}
 
-			curr = &Json{a[idx]}
-			continue
+		if k.append {
+			child, ok := s.set(nil, keys[1:], value)
+			if !ok {
+				return a, false
+			}
+			return append(a, child), true
 		}
 
-		// Value or nil => we force the rewrite
-		m := make(map[string]interface{})
-		curr.data = m
+		// Pad with nulls, ie items[3] on a 2 items array
+		if k.bracket && k.isIndex && k.index >= len(a) {
+			if k.index-len(a) > maxPadding {
+				return a, false
+			}
+			a = append(a, make([]interface{}, k.index-len(a)+1)...)
+		}
 
-		// Assign
-		if i == lastIndex {
-			m[k] = newValue
-			return true
+		idx, ok := arrayIndex(k, len(a))
+		if !ok {
+			return a, false
 		}
+		child, ok := s.set(a[idx], keys[1:], value)
+		if ok {
+			a[idx] = child
+		}
+		return a, ok
+	}
 
-		m[k] = make(map[string]interface{})
-		curr = &Json{m[k]}
+	// Value or nil => we force the rewrite
+	if k.wildcard {
+		return data, false
 	}
 
-	return false
+	// Array for index properties, ie items[0] or items[]
+	if k.bracket && (k.append || k.isIndex) {
+		if k.index < 0 {
+			return data, false
+		}
+		return s.set([]interface{}{}, keys, value)
+	}
+
+	child, ok := s.set(nil, keys[1:], value)
+	if !ok {
+		return data, false
+	}
+	if s.ordered {
+		o := newOrderedObject(1)
+		o.set(k.name, child)
+		return o, true
+	}
+	return map[string]interface{}{k.name: child}, true
 }
 
-// Unset deletes the value
-func (j *Json) Unset(path string) bool {
-	keys := createPath(path)
-	curr := j
-	lastIndex := len(keys) - 1
-
-	for i, k := range keys {
-		// Get  as object
-		if o := curr.AsObject(); o != nil {
-			if i == lastIndex {
-				delete(o, k)
-				return true
-			}
-			val, ok := o[k]
-			if !ok {
-				return false
+// unsetValue deletes the values matching keys in data, array items are removed
+// Returns the updated data, which must be written back in the parent
+func unsetValue(data interface{}, keys []pathKey) (interface{}, bool) {
+	k := keys[0]
+	last := len(keys) == 1
+
+	// Unset in object
+	if o, ok := objectOf(data); ok {
+		if k.wildcard {
+			unset := false
+			for _, name := range objectKeys(data) {
+				if last {
+					deleteMember(data, name)
+					unset = true
+				} else if child, ok := unsetValue(o[name], keys[1:]); ok {
+					o[name] = child
+					unset = true
+				}
 			}
-			curr = &Json{val}
-			continue
+			return data, unset
+		}
+		if last {
+			deleteMember(data, k.name)
+			return data, true
+		}
+		val, ok := o[k.name]
+		if !ok {
+			return data, false
 		}
+		child, ok := unsetValue(val, keys[1:])
+		if ok {
+			o[k.name] = child
+		}
+		return data, ok
+	}
 
-		// Get as array
-		if a := curr.AsArray(); a != nil {
-			// Must be an int
-			idx, e := strconv.Atoi(k)
-			if e != nil || idx < 0 || idx >= len(a) {
-				return false
+	// Unset in array
+	if a, ok := data.([]interface{}); ok {
+		if k.wildcard {
+			if last {
+				return []interface{}{}, true
 			}
-			if i == lastIndex {
-				a[idx] = nil
-				return true
+			unset := false
+			for i, val := range a {
+				if child, ok := unsetValue(val, keys[1:]); ok {
+					a[i] = child
+					unset = true
+				}
 			}
-			curr = &Json{a[idx]}
-			continue
+			return a, unset
+		}
+		idx, ok := arrayIndex(k, len(a))
+		if !ok {
+			return a, false
+		}
+		if last {
+			spliced := make([]interface{}, 0, len(a)-1)
+			spliced = append(spliced, a[:idx]...)
+			return append(spliced, a[idx+1:]...), true
 		}
+		child, ok := unsetValue(a[idx], keys[1:])
+		if ok {
+			a[idx] = child
+		}
+		return a, ok
+	}
 
-		// Not found
-		return false
+	// Not found
+	return data, false
+}
+
+// arrayIndex resolves the index of k in an array of length l
+// Negative indexes start from the end
+func arrayIndex(k pathKey, l int) (int, bool) {
+	if !k.isIndex {
+		return 0, false
 	}
-	return false
+	idx := k.index
+	if idx < 0 {
+		idx += l
+	}
+	if idx < 0 || idx >= l {
+		return 0, false
+	}
+	return idx, true
 }
 
 // Rewrite changes a path
+// In ordered objects, a key renamed in the same object keeps its position
 func (j *Json) Rewrite(oldPath string, newPath string) bool {
-	d := j.Get(oldPath).Data()
+	if j.renameKey(oldPath, newPath) {
+		return true
+	}
+	d := dataOf(j.Get(oldPath))
 	return j.Unset(oldPath) && j.Set(newPath, d)
 }
 
+// renameKey renames a key of an ordered object in place, ie "a.b" => "a.c"
+func (j *Json) renameKey(oldPath string, newPath string) bool {
+	j.modify()
+	oldKeys, newKeys := pathKeys(oldPath), pathKeys(newPath)
+	if len(oldKeys) == 0 || len(oldKeys) != len(newKeys) || hasWildcard(oldKeys) || hasWildcard(newKeys) {
+		return false
+	}
+	last := len(oldKeys) - 1
+	if oldKeys[last].isIndex || oldKeys[last].append || newKeys[last].isIndex || newKeys[last].append {
+		return false
+	}
+	if compareKeys(oldKeys[:last], newKeys[:last]) != 0 {
+		return false
+	}
+	parent, ok := getValue(j.data, oldKeys[:last])
+	if !ok {
+		return false
+	}
+	o, ok := parent.(*orderedObject)
+	if !ok {
+		return false
+	}
+	if _, ok := o.values[oldKeys[last].name]; !ok {
+		return false
+	}
+	o.rename(oldKeys[last].name, newKeys[last].name)
+	return true
+}
+
 // Wrap the current json to a new json
 // Example : { "pi": 3.14 }.Wrap("const") => { "const": { "pi": 3.14 }} }
 // Returns the new parent or nilJson if error
@@ -421,28 +1054,65 @@ func (j *Json) Wrap(path string) *Json {
 }
 
 // ForEach : Iterates over elements of collection and invokes iteratee for each element.
+// Members of ordered objects are iterated in order.
 // Iteratee functions may exit iteration early by explicitly returning false.
 func (j *Json) ForEach(iteratee func(k string, v *Json) bool) {
+	j.load()
 	if iteratee == nil {
 		return
 	}
 
-	if o := j.AsObject(); o != nil {
+	if o, ok := j.data.(*orderedObject); ok {
+		for _, k := range o.ordered() {
+			if !iteratee(k, &Json{data: o.values[k], path: joinPath(j.path, EscapePath(k)), coerce: j.coerce}) {
+				break
+			}
+		}
+	} else if o := j.AsObject(); o != nil {
 		for k, v := range o {
-			if !iteratee(k, &Json{v}) {
+			if !iteratee(k, &Json{data: v, path: joinPath(j.path, EscapePath(k)), coerce: j.coerce}) {
 				break
 			}
 		}
 	} else if a := j.AsArray(); a != nil {
 		for i, v := range a {
-			if !iteratee(strconv.Itoa(i), &Json{v}) {
+			if !iteratee(strconv.Itoa(i), &Json{data: v, path: joinPath(j.path, "["+strconv.Itoa(i)+"]"), coerce: j.coerce}) {
 				break
 			}
 		}
 	}
 }
 
+// ForEachSorted : Iterates over elements of collection like ForEach, object members in key order
+func (j *Json) ForEachSorted(iteratee func(k string, v *Json) bool) {
+	j.load()
+	if iteratee == nil {
+		return
+	}
+
+	if o, ok := objectOf(j.data); ok {
+		for _, k := range j.SortedKeys() {
+			if !iteratee(k, &Json{data: o[k], path: joinPath(j.path, EscapePath(k)), coerce: j.coerce}) {
+				break
+			}
+		}
+		return
+	}
+	j.ForEach(iteratee)
+}
+
+// SortedKeys : creates a sorted array of the own property names of object.
+func (j *Json) SortedKeys() []string {
+	j.load()
+	o, ok := objectOf(j.data)
+	if !ok {
+		return nil
+	}
+	return sortedKeys(o)
+}
+
 // Keys : creates an array of the own property names of object.
+// Keys are in insertion order for ordered objects, see SortedKeys for a deterministic order
 func (j *Json) Keys() []string {
 	var keys []string
 
@@ -469,25 +1139,124 @@ func (j *Json) Values() []*Json {
 	return values
 }
 
-// Clone to clone a json
-// Not the best performance cause we marshal / unmarshal
+// Clone to clone a json without marshalling
+// Objects and arrays are deeply copied, values are kept as is (ie json.Number, time.Time or structs stay the same).
+// Cyclic objects and arrays are cloned into the same cycle
 func (j *Json) Clone() *Json {
-	bytes, err := j.MarshalJSON()
-	if err != nil {
-		return Nil()
+	j.load()
+	return &Json{data: cloneValue(j.data)}
+}
+
+// cloneValue deeply copies objects and arrays
+func cloneValue(v interface{}) interface{} {
+	switch v.(type) {
+	case map[string]interface{}, *orderedObject, []interface{}:
+		return (&cloner{}).clone(v)
+	default:
+		return v
+	}
+}
+
+// cloneKey identifies an object or an array being cloned
+type cloneKey struct {
+	ptr uintptr
+	len int
+}
+
+// cloneParent is an object or an array being cloned
+type cloneParent struct {
+	key   cloneKey
+	clone interface{}
+}
+
+// cloner deeply copies objects and arrays
+// It keeps the parents being cloned to handle cycles
+type cloner struct {
+	parents []cloneParent
+}
+
+func (c *cloner) clone(v interface{}) interface{} {
+	switch cv := v.(type) {
+	case map[string]interface{}:
+		if cv == nil {
+			return cv
+		}
+		key := cloneKey{ptr: reflect.ValueOf(cv).Pointer()}
+		if m, ok := c.parent(key); ok {
+			return m
+		}
+		m := make(map[string]interface{}, len(cv))
+		c.parents = append(c.parents, cloneParent{key, m})
+		for k, item := range cv {
+			m[k] = c.clone(item)
+		}
+		c.parents = c.parents[:len(c.parents)-1]
+		return m
+
+	case *orderedObject:
+		if cv == nil {
+			return cv
+		}
+		key := cloneKey{ptr: reflect.ValueOf(cv).Pointer()}
+		if o, ok := c.parent(key); ok {
+			return o
+		}
+		keys := cv.ordered()
+		o := newOrderedObject(len(keys))
+		c.parents = append(c.parents, cloneParent{key, o})
+		for _, k := range keys {
+			o.set(k, c.clone(cv.values[k]))
+		}
+		c.parents = c.parents[:len(c.parents)-1]
+		return o
+
+	case []interface{}:
+		if len(cv) == 0 {
+			return cv[:0:0]
+		}
+		key := cloneKey{ptr: reflect.ValueOf(cv).Pointer(), len: len(cv)}
+		if a, ok := c.parent(key); ok {
+			return a
+		}
+		a := make([]interface{}, len(cv))
+		c.parents = append(c.parents, cloneParent{key, a})
+		for i, item := range cv {
+			a[i] = c.clone(item)
+		}
+		c.parents = c.parents[:len(c.parents)-1]
+		return a
+
+	default:
+		return v
+	}
+}
+
+// parent returns the clone of a parent being cloned
+func (c *cloner) parent(key cloneKey) (interface{}, bool) {
+	for i := len(c.parents) - 1; i >= 0; i-- {
+		if c.parents[i].key == key {
+			return c.parents[i].clone, true
+		}
 	}
-	return FromBytes(bytes)
+	return nil, false
 }
 
 // Merge to merge multiples JSON into a single one
+// Only top-level keys of objects are merged, see DeepMerge for a recursive merge
+// The result is ordered if a json is ordered, keys are kept in order of appearance
 func Merge(jsons ...*Json) *Json {
-	res := New()
-	m := make(map[string]interface{})
+	var res interface{} = make(map[string]interface{})
 	for _, j := range jsons {
+		if _, ok := dataOf(j).(*orderedObject); ok {
+			res = newOrderedObject(0)
+			break
+		}
+	}
+	for _, j := range jsons {
+		o := j.AsObject()
 		for _, k := range j.Keys() {
-			m[k] = j.AsObject()[k]
+			setMember(res, k, o[k])
 		}
 	}
-	res.data = m
-	return res
+	return &Json{data: res}
 }
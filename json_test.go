@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/datasweet/jsonmap"
 	"github.com/stretchr/testify/assert"
@@ -180,8 +181,47 @@ func TestSet(t *testing.T) {
 		assert.True(t, j.Set("items[3].age", 37))
 		assert.JSONEq(t, `{ "items": [1, 2, 3.14, {"name": "Thomas CHARLOT", "age": 37 }, 5] }`, j.Stringify())
 
-		assert.False(t, j.Set("items[7]", 11))
-		assert.JSONEq(t, `{ "items": [1, 2, 3.14, {"name": "Thomas CHARLOT", "age": 37 }, 5] }`, j.Stringify())
+		assert.True(t, j.Set("items[7]", 11))
+		assert.JSONEq(t, `{ "items": [1, 2, 3.14, {"name": "Thomas CHARLOT", "age": 37 }, 5, null, null, 11] }`, j.Stringify())
+
+		assert.True(t, j.Set("items[]", 12))
+		assert.JSONEq(t, `{ "items": [1, 2, 3.14, {"name": "Thomas CHARLOT", "age": 37 }, 5, null, null, 11, 12] }`, j.Stringify())
+
+		assert.False(t, j.Set("items[-10]", 0))
+	})
+
+	t.Run("can't pad arrays with too many nulls", func(t *testing.T) {
+		j := jsonmap.FromString(`{ "items": [1] }`)
+		assert.False(t, j.Set("items[9223372036854775806]", 1))
+		assert.False(t, j.Set("items[10000000000]", 1))
+		assert.False(t, j.Set("items[10002]", 1))
+		assert.JSONEq(t, `{ "items": [1] }`, j.Stringify())
+		assert.True(t, j.Set("items[10001]", 1))
+		assert.Len(t, j.Get("items").AsArray(), 10002)
+
+		j = jsonmap.New()
+		assert.False(t, j.Set("items[9223372036854775807]", 1))
+		assert.False(t, j.Set("a[0][9223372036854775807]", 1))
+		assert.JSONEq(t, `{}`, j.Stringify())
+	})
+
+	t.Run("can create arrays for missing index properties", func(t *testing.T) {
+		j := jsonmap.New()
+		assert.True(t, j.Set("tags[]", "a"))
+		assert.True(t, j.Set("tags[]", "b"))
+		assert.True(t, j.Set("matrix[1][2]", 1))
+		assert.True(t, j.Set("people[0].name", "john"))
+		assert.True(t, j.Set("people[].name", "jane"))
+		assert.True(t, j.Set("buckets.1.value", 3))
+		assert.JSONEq(t, `{
+			"tags": ["a", "b"],
+			"matrix": [null, [null, null, 1]],
+			"people": [{ "name": "john" }, { "name": "jane" }],
+			"buckets": { "1": { "value": 3 } }
+		}`, j.Stringify())
+
+		assert.False(t, j.Set("buckets[]", 1))
+		assert.False(t, j.Set("missing[-1]", 1))
 	})
 
 	t.Run("can set nil", func(t *testing.T) {
@@ -252,6 +292,38 @@ func TestSet(t *testing.T) {
 	})
 }
 
+func TestAppend(t *testing.T) {
+	j := jsonmap.FromString(`{ "tags": ["a"], "name": "john" }`)
+
+	assert.True(t, j.Append("tags", "b", "c"))
+	assert.True(t, j.Append("new.tags", 1, []int{2, 3}))
+	assert.True(t, j.Append("empty"))
+	assert.False(t, j.Append("name", "doe"))
+	assert.JSONEq(t, `{ "tags": ["a", "b", "c"], "name": "john", "new": { "tags": [1, [2, 3]] }, "empty": [] }`, j.Stringify())
+
+	assert.True(t, j.Append("new.tags[1]", 4))
+	assert.JSONEq(t, `[1, [2, 3, 4]]`, j.Get("new.tags").Stringify())
+	assert.False(t, j.Append("new.*", 5))
+}
+
+func TestInsert(t *testing.T) {
+	j := jsonmap.FromString(`{ "items": [1, 2, 3], "sub": { "items": [] } }`)
+
+	assert.True(t, j.Insert("items", 0, 0))
+	assert.True(t, j.Insert("items", 2, 1.5))
+	assert.True(t, j.Insert("items", 5, 4))
+	assert.True(t, j.Insert("items", -1, 3.5))
+	assert.JSONEq(t, `[0, 1, 1.5, 2, 3, 3.5, 4]`, j.Get("items").Stringify())
+
+	assert.True(t, j.Insert("sub.items", 0, "a"))
+	assert.JSONEq(t, `["a"]`, j.Get("sub.items").Stringify())
+
+	assert.False(t, j.Insert("items", 8, 0))
+	assert.False(t, j.Insert("items", -8, 0))
+	assert.False(t, j.Insert("sub", 0, 0))
+	assert.False(t, j.Insert("missing", 0, 0))
+}
+
 func TestWrap(t *testing.T) {
 	j := jsonmap.New()
 	assert.True(t, j.Set("pi", 3.14))
@@ -334,6 +406,67 @@ func TestUnset(t *testing.T) {
 	assert.True(t, j.Get("object.sub[1]").IsNil())
 }
 
+func TestUnsetArrayItem(t *testing.T) {
+	j := jsonmap.FromString(`{ "items": [1, 2, 3, 4], "sub": [[1, 2], [3, 4]] }`)
+
+	assert.True(t, j.Unset("items[1]"))
+	assert.JSONEq(t, `[1, 3, 4]`, j.Get("items").Stringify())
+	assert.True(t, j.Unset("items[-1]"))
+	assert.JSONEq(t, `[1, 3]`, j.Get("items").Stringify())
+	assert.False(t, j.Unset("items[2]"))
+
+	assert.True(t, j.Unset("sub[1][0]"))
+	assert.True(t, j.Unset("sub[*][0]"))
+	assert.JSONEq(t, `[[2], []]`, j.Get("sub").Stringify())
+	assert.True(t, j.Unset("sub[*]"))
+	assert.JSONEq(t, `[]`, j.Get("sub").Stringify())
+
+	root := jsonmap.FromString(`[1, 2, 3]`)
+	assert.True(t, root.Unset("[0]"))
+	assert.JSONEq(t, `[2, 3]`, root.Stringify())
+
+	assert.NoError(t, root.UnsetPointer("/1"))
+	assert.JSONEq(t, `[2]`, root.Stringify())
+}
+
+func TestUnsetAll(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"items": [{ "a": 1, "b": 2 }, { "a": 3 }, { "a": 4 }, { "a": 5 }],
+		"name": "john",
+		"age": 37
+	}`)
+
+	assert.Equal(t, 4, j.UnsetAll("items[0]", "items[2]", "name", "unknown", "items[0].a", "items[2]"))
+	assert.JSONEq(t, `{ "items": [{ "a": 3 }, { "a": 5 }], "age": 37 }`, j.Stringify())
+
+	assert.Equal(t, 2, j.UnsetAll("items[*].a"))
+	assert.JSONEq(t, `{ "items": [{}, {}], "age": 37 }`, j.Stringify())
+
+	assert.Equal(t, 0, j.UnsetAll())
+}
+
+func TestCompact(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"tags": [null, "a", null, "b"],
+		"items": [{ "tags": [null] }, { "tags": ["c", null] }, { "tags": "d" }],
+		"name": null
+	}`)
+
+	assert.Equal(t, 2, j.Compact("tags"))
+	assert.Equal(t, 2, j.Compact("items[*].tags"))
+	assert.Equal(t, 0, j.Compact("name"))
+	assert.Equal(t, 0, j.Compact("unknown"))
+	assert.JSONEq(t, `{
+		"tags": ["a", "b"],
+		"items": [{ "tags": [] }, { "tags": ["c"] }, { "tags": "d" }],
+		"name": null
+	}`, j.Stringify())
+
+	root := jsonmap.FromString(`[null, 1, null]`)
+	assert.Equal(t, 2, root.Compact(""))
+	assert.JSONEq(t, `[1]`, root.Stringify())
+}
+
 func TestRewrite(t *testing.T) {
 	j := jsonmap.FromString(jsonTest)
 	assert.False(t, j.Get("object.sub[0].a").IsNil())
@@ -385,6 +518,65 @@ func TestClone(t *testing.T) {
 	assert.Equal(t, int64(4), clone.Get("object.sub[0].a").AsInt())
 	assert.Equal(t, int64(12345), clone.Get("test").AsInt())
 	assert.Equal(t, int64(0), j.Get("test").AsInt())
+
+	// values are kept as is
+	now := time.Now()
+	j = jsonmap.New()
+	j.Set("time", now)
+	j.Set("int", 42)
+	j.Set("struct", struct{ A int }{A: 1})
+	j.Set("empty", []interface{}{})
+	clone = j.Clone()
+	assert.Equal(t, now, clone.Get("time").Data())
+	assert.Equal(t, 42, clone.Get("int").Data())
+	assert.Equal(t, struct{ A int }{A: 1}, clone.Get("struct").Data())
+	assert.Equal(t, []interface{}{}, clone.Get("empty").Data())
+
+	// cycles
+	j = jsonmap.FromString(`{ "name": "root", "items": [1] }`)
+	j.Set("self", j)
+	items := make([]interface{}, 2)
+	items[0] = "item"
+	items[1] = items
+	j.Set("items", items)
+	clone = j.Clone()
+	clone.Set("name", "clone")
+	clone.Set("items[0]", "cloned item")
+	assert.Equal(t, "clone", clone.Get("self.self.name").AsString())
+	assert.Equal(t, "cloned item", clone.Get("self.items[1][1][0]").AsString())
+	assert.Equal(t, "root", j.Get("self.name").AsString())
+	assert.Equal(t, "item", j.Get("items[1][0]").AsString())
+}
+
+// aggregationResponse builds a large elasticsearch aggregation response
+func aggregationResponse() *jsonmap.Json {
+	j := jsonmap.New()
+	for i := 0; i < 100; i++ {
+		bucket := jsonmap.New()
+		bucket.Set("key", fmt.Sprintf("term-%d", i))
+		bucket.Set("doc_count", i)
+		for k := 0; k < 20; k++ {
+			bucket.Set("histogram.buckets[]", jsonmap.FromString(fmt.Sprintf(`{ "key": %d, "doc_count": %d, "avg": { "value": 12.5 } }`, k, k)))
+		}
+		j.Set("aggregations.terms.buckets[]", bucket)
+	}
+	return j
+}
+
+func BenchmarkClone(b *testing.B) {
+	j := aggregationResponse()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		j.Clone()
+	}
+}
+
+func BenchmarkCloneMarshal(b *testing.B) {
+	j := aggregationResponse()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonmap.FromString(j.Stringify())
+	}
 }
 
 func TestMerge(t *testing.T) {
@@ -404,3 +596,129 @@ func TestMerge(t *testing.T) {
 	assert.Equal(t, "john", merge.Get("name").AsString())
 	assert.Len(t, merge.Keys(), 7)
 }
+
+func TestAsOr(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+	assert.Equal(t, "hello", j.Get("string").AsStringOr("def"))
+	assert.Equal(t, "def", j.Get("number").AsStringOr("def"))
+	assert.Equal(t, "def", j.Get("unknown").AsStringOr("def"))
+
+	assert.Equal(t, int64(123), j.Get("number").AsIntOr(-1))
+	assert.Equal(t, int64(-1), j.Get("string").AsIntOr(-1))
+	assert.Equal(t, int64(-1), j.Get("unknown").AsIntOr(-1))
+
+	assert.Equal(t, uint64(123), j.Get("number").AsUintOr(7))
+	assert.Equal(t, uint64(7), j.Get("bool").AsUintOr(7))
+
+	assert.Equal(t, float64(123), j.Get("number").AsFloatOr(3.14))
+	assert.Equal(t, 3.14, j.Get("object").AsFloatOr(3.14))
+
+	assert.Equal(t, true, j.Get("bool").AsBoolOr(false))
+	assert.Equal(t, true, j.Get("string").AsBoolOr(true))
+	assert.Equal(t, false, j.Get("unknown").AsBoolOr(false))
+}
+
+func TestAsTime(t *testing.T) {
+	j := jsonmap.FromString(`{ "date": "2020-03-01T10:20:30Z", "nano": "2020-03-01T10:20:30.123+01:00", "wrong": "yesterday", "ms": 1583058030000 }`)
+	expected := time.Date(2020, 3, 1, 10, 20, 30, 0, time.UTC)
+
+	assert.True(t, expected.Equal(j.Get("date").AsTime()))
+	assert.True(t, time.Date(2020, 3, 1, 9, 20, 30, 123000000, time.UTC).Equal(j.Get("nano").AsTime()))
+	assert.True(t, j.Get("wrong").AsTime().IsZero())
+	assert.True(t, j.Get("ms").AsTime().IsZero())
+	assert.True(t, expected.Equal(j.Get("unknown").AsTimeOr(expected)))
+
+	j.Set("now", expected)
+	assert.True(t, expected.Equal(j.Get("now").AsTime()))
+}
+
+func TestSetReplaceNestedValue(t *testing.T) {
+	j := jsonmap.FromString(`{ "a": 1, "items": [1, 2] }`)
+	assert.True(t, j.Set("a.b", 2))
+	assert.True(t, j.Set("items[1].c", 3))
+	assert.JSONEq(t, `{ "a": { "b": 2 }, "items": [1, { "c": 3 }] }`, j.Stringify())
+}
+
+func TestGetNegativeIndex(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+	assert.Equal(t, int64(5), j.Get("array[-1]").AsInt())
+	assert.Equal(t, int64(1), j.Get("array[-5]").AsInt())
+	assert.True(t, j.Get("array[-6]").IsNil())
+	assert.Equal(t, "b", j.Get("object.sub[-1].1").AsString())
+	assert.True(t, j.Has("object.sub[-2]"))
+
+	assert.True(t, j.Set("array[-2]", 40))
+	assert.False(t, j.Set("array[-6]", 0))
+	assert.JSONEq(t, `[1, 2, 3, 40, 5]`, j.Get("array").Stringify())
+
+	assert.True(t, j.Unset("object.sub[-1].a"))
+	assert.JSONEq(t, `{ "1": "b" }`, j.Get("object.sub[1]").Stringify())
+}
+
+func TestGetAll(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"hits": {
+			"hits": [
+				{ "_source": { "name": "a", "tags": ["x", "y"] } },
+				{ "_source": { "name": "b", "tags": [] } },
+				{ "_source": { "tags": ["z"] } }
+			]
+		}
+	}`)
+
+	var names []string
+	var paths []string
+	for _, v := range j.GetAll("hits.hits[*]._source.name") {
+		names = append(names, v.AsString())
+		paths = append(paths, v.Path())
+	}
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Equal(t, []string{"hits.hits[0]._source.name", "hits.hits[1]._source.name"}, paths)
+
+	var tags []string
+	for _, v := range j.GetAll("hits.hits[*]._source.tags[-1]") {
+		tags = append(tags, v.AsString())
+	}
+	assert.Equal(t, []string{"y", "z"}, tags)
+
+	assert.Len(t, j.GetAll("hits.hits[1]._source.*"), 2)
+	assert.Len(t, j.GetAll("hits.hits.*._source"), 3)
+	assert.Len(t, j.GetAll("hits.hits[0]"), 1)
+	assert.Empty(t, j.GetAll("hits.unknown[*]"))
+	assert.Equal(t, "a", j.Get("hits.hits[*]._source.name").AsString())
+	assert.True(t, j.Has("hits.hits[*]._source.name"))
+	assert.False(t, j.Has("hits.hits[*]._source.unknown"))
+
+	sub := j.Get("hits")
+	assert.Equal(t, "hits.hits[2]._source.tags[0]", sub.GetAll("hits[2]._source.tags[*]")[0].Path())
+}
+
+func TestWildcardSetUnset(t *testing.T) {
+	j := jsonmap.FromString(`{ "items": [{ "a": 1, "b": 1 }, { "a": 2 }, 3] }`)
+
+	// scalar matches are kept
+	assert.True(t, j.Set("items[*].visible", true))
+	assert.JSONEq(t, `{ "items": [{ "a": 1, "b": 1, "visible": true }, { "a": 2, "visible": true }, 3] }`, j.Stringify())
+
+	assert.True(t, j.Unset("items[*].visible"))
+	assert.True(t, j.Unset("items[*].b"))
+	assert.JSONEq(t, `{ "items": [{ "a": 1 }, { "a": 2 }, 3] }`, j.Stringify())
+
+	assert.False(t, j.Set("unknown[*].a", 1))
+	assert.False(t, j.Unset("unknown[*]"))
+
+	s := jsonmap.FromString(`{ "tags": ["a", null], "n": 1 }`)
+	assert.False(t, s.Set("tags[*].name", "x"))
+	assert.False(t, s.Set("*.name", "x"))
+	assert.JSONEq(t, `{ "tags": ["a", null], "n": 1 }`, s.Stringify())
+	assert.True(t, s.Set("tags[*]", "x"))
+	assert.JSONEq(t, `{ "tags": ["x", "x"], "n": 1 }`, s.Stringify())
+
+	// escaped "*" key
+	e := jsonmap.FromString(`{ "*": 1, "a": 2 }`)
+	assert.Equal(t, int64(1), e.Get(`\*`).AsInt())
+	assert.Equal(t, int64(1), e.MustGet(jsonmap.EscapePath("*")).AsInt())
+	assert.True(t, e.Set(`\*`, 3))
+	assert.JSONEq(t, `{ "*": 3, "a": 2 }`, e.Stringify())
+	assert.Equal(t, `\*`, e.GetAll("*")[0].Path())
+}
@@ -0,0 +1,1086 @@
+package jsonmap
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// maxQueryInt is the greatest integer allowed in a query (I-JSON range)
+const maxQueryInt = 1<<53 - 1
+
+// QueryError is returned when a JSONPath expression is malformed
+type QueryError struct {
+	Query  string
+	Offset int // offset of the error in the query
+	msg    string
+}
+
+// Error implements the error interface
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("jsonmap: query '%s' at offset %d: %s", e.Query, e.Offset, e.msg)
+}
+
+// Query selects all the nodes matching a JSONPath expression (RFC 9535).
+// Supported syntax :
+//   - root $, child segments .name, ['name'], [0], [-1] and wildcards .* and [*]
+//   - descendant segments ..name, ..*, ..[0]
+//   - slices [start:end:step] and unions ['a', 'b', 0]
+//   - filters [?@.price < 10 && @.category == 'fiction'], [?(@.isbn)]
+//   - functions length(), count(), match(), search() and value()
+//
+// Object members are visited in key order.
+// Each returned Json has its Path() set to the location of the node.
+// Example : Query("$.aggregations..buckets[?(@.doc_count > 10)].key")
+func (j *Json) Query(expr string) ([]*Json, error) {
+	j.load()
+	q, err := parseQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+	nodes := q.eval(&queryContext{root: j.data}, j.data)
+	res := make([]*Json, len(nodes))
+	for i, n := range nodes {
+		res[i] = &Json{data: n.value, path: joinPath(j.path, n.path), coerce: j.coerce}
+	}
+	return res, nil
+}
+
+// queryNode is a node selected by a query
+type queryNode struct {
+	value interface{}
+	path  string
+}
+
+// queryContext holds evaluation state
+type queryContext struct {
+	root interface{}
+}
+
+// children returns the children of value in document order
+func children(n queryNode) []queryNode {
+	switch v := n.value.(type) {
+	case []interface{}:
+		nodes := make([]queryNode, len(v))
+		for i, item := range v {
+			nodes[i] = queryNode{item, indexPath(n.path, i)}
+		}
+		return nodes
+	case map[string]interface{}, *orderedObject:
+		o, _ := objectOf(v)
+		keys := objectKeys(v)
+		nodes := make([]queryNode, len(keys))
+		for i, k := range keys {
+			nodes[i] = queryNode{o[k], keyPath(n.path, k)}
+		}
+		return nodes
+	}
+	return nil
+}
+
+// jsonPath is a compiled query
+type jsonPath struct {
+	relative bool // starts with @ instead of $
+	segments []*querySegment
+}
+
+// singular reports if the query selects at most one node
+func (q *jsonPath) singular() bool {
+	for _, s := range q.segments {
+		if s.descendant || len(s.selectors) != 1 {
+			return false
+		}
+		switch s.selectors[0].(type) {
+		case nameSelector, indexSelector:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func (q *jsonPath) eval(ctx *queryContext, current interface{}) []queryNode {
+	start := queryNode{value: ctx.root}
+	if q.relative {
+		start.value = current
+	}
+	nodes := []queryNode{start}
+	for _, s := range q.segments {
+		var next []queryNode
+		for _, n := range nodes {
+			next = s.apply(ctx, n, next)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+type querySegment struct {
+	descendant bool
+	selectors  []selector
+}
+
+func (s *querySegment) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	for _, sel := range s.selectors {
+		out = sel.apply(ctx, n, out)
+	}
+	if s.descendant {
+		for _, c := range children(n) {
+			out = s.apply(ctx, c, out)
+		}
+	}
+	return out
+}
+
+// selector selects children of a node
+type selector interface {
+	apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode
+}
+
+type nameSelector string
+
+func (s nameSelector) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	if o, ok := objectOf(n.value); ok {
+		if v, ok := o[string(s)]; ok {
+			out = append(out, queryNode{v, keyPath(n.path, string(s))})
+		}
+	}
+	return out
+}
+
+type wildcardSelector struct{}
+
+func (s wildcardSelector) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	return append(out, children(n)...)
+}
+
+type indexSelector int
+
+func (s indexSelector) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	if a, ok := n.value.([]interface{}); ok {
+		i := int(s)
+		if i < 0 {
+			i += len(a)
+		}
+		if i >= 0 && i < len(a) {
+			out = append(out, queryNode{a[i], indexPath(n.path, i)})
+		}
+	}
+	return out
+}
+
+type sliceSelector struct {
+	start, end *int
+	step       int
+}
+
+func (s sliceSelector) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	a, ok := n.value.([]interface{})
+	if !ok || s.step == 0 {
+		return out
+	}
+	l := len(a)
+	normalize := func(i int) int {
+		if i < 0 {
+			return l + i
+		}
+		return i
+	}
+	bound := func(i, min, max int) int {
+		if i < min {
+			return min
+		}
+		if i > max {
+			return max
+		}
+		return i
+	}
+
+	if s.step > 0 {
+		start, end := 0, l
+		if s.start != nil {
+			start = normalize(*s.start)
+		}
+		if s.end != nil {
+			end = normalize(*s.end)
+		}
+		lower, upper := bound(start, 0, l), bound(end, 0, l)
+		for i := lower; i < upper; i += s.step {
+			out = append(out, queryNode{a[i], indexPath(n.path, i)})
+		}
+		return out
+	}
+
+	start, end := l-1, -l-1
+	if s.start != nil {
+		start = normalize(*s.start)
+	}
+	if s.end != nil {
+		end = normalize(*s.end)
+	}
+	upper, lower := bound(start, -1, l-1), bound(end, -1, l-1)
+	for i := upper; lower < i; i += s.step {
+		out = append(out, queryNode{a[i], indexPath(n.path, i)})
+	}
+	return out
+}
+
+type filterSelector struct {
+	expr logicalExpr
+}
+
+func (s filterSelector) apply(ctx *queryContext, n queryNode, out []queryNode) []queryNode {
+	for _, c := range children(n) {
+		if s.expr.test(ctx, c.value) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// logicalExpr is a filter expression
+type logicalExpr interface {
+	test(ctx *queryContext, current interface{}) bool
+}
+
+type orExpr []logicalExpr
+
+func (e orExpr) test(ctx *queryContext, current interface{}) bool {
+	for _, sub := range e {
+		if sub.test(ctx, current) {
+			return true
+		}
+	}
+	return false
+}
+
+type andExpr []logicalExpr
+
+func (e andExpr) test(ctx *queryContext, current interface{}) bool {
+	for _, sub := range e {
+		if !sub.test(ctx, current) {
+			return false
+		}
+	}
+	return true
+}
+
+type notExpr struct {
+	expr logicalExpr
+}
+
+func (e notExpr) test(ctx *queryContext, current interface{}) bool {
+	return !e.expr.test(ctx, current)
+}
+
+// existExpr tests if a query selects at least one node
+type existExpr struct {
+	query *jsonPath
+}
+
+func (e existExpr) test(ctx *queryContext, current interface{}) bool {
+	return len(e.query.eval(ctx, current)) > 0
+}
+
+type compareExpr struct {
+	op          string
+	left, right comparand
+}
+
+func (e compareExpr) test(ctx *queryContext, current interface{}) bool {
+	a, aok := e.left.compValue(ctx, current)
+	b, bok := e.right.compValue(ctx, current)
+
+	switch e.op {
+	case "==":
+		return compareEqual(a, aok, b, bok)
+	case "!=":
+		return !compareEqual(a, aok, b, bok)
+	case "<":
+		return aok && bok && compareLess(a, b)
+	case "<=":
+		return aok && bok && (compareLess(a, b) || compareEqual(a, aok, b, bok))
+	case ">":
+		return aok && bok && compareLess(b, a)
+	case ">=":
+		return aok && bok && (compareLess(b, a) || compareEqual(a, aok, b, bok))
+	}
+	return false
+}
+
+// compareEqual compares two comparands, an empty comparand is only equal to an empty one
+func compareEqual(a interface{}, aok bool, b interface{}, bok bool) bool {
+	if !aok || !bok {
+		return !aok && !bok
+	}
+	return valuesEqual(a, b)
+}
+
+// compareLess compares numbers exactly, consistently with valuesEqual, or strings
+func compareLess(a, b interface{}) bool {
+	if kindOf(a) == NumberKind {
+		c, ok := compareNumbers(a, b)
+		return ok && c < 0
+	}
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		return ok && sa < sb
+	}
+	return false
+}
+
+// numberOf converts a number value to float64
+func numberOf(v interface{}) (float64, bool) {
+	if kindOf(v) != NumberKind {
+		return 0, false
+	}
+	return (&Json{data: v}).asFloat()
+}
+
+// comparand is an operand of a comparison
+// ok is false when the operand is empty (ie a query selecting nothing)
+type comparand interface {
+	compValue(ctx *queryContext, current interface{}) (v interface{}, ok bool)
+}
+
+type literalExpr struct {
+	value interface{}
+}
+
+func (e literalExpr) compValue(ctx *queryContext, current interface{}) (interface{}, bool) {
+	return e.value, true
+}
+
+type singularQueryExpr struct {
+	query *jsonPath
+}
+
+func (e singularQueryExpr) compValue(ctx *queryContext, current interface{}) (interface{}, bool) {
+	nodes := e.query.eval(ctx, current)
+	if len(nodes) != 1 {
+		return nil, false
+	}
+	return nodes[0].value, true
+}
+
+// function types
+const (
+	valueType = iota
+	logicalType
+	nodesType
+)
+
+// queryFunction describes a function extension
+type queryFunction struct {
+	params []int
+	result int
+}
+
+var queryFunctions = map[string]queryFunction{
+	"length": {[]int{valueType}, valueType},
+	"count":  {[]int{nodesType}, valueType},
+	"match":  {[]int{valueType, valueType}, logicalType},
+	"search": {[]int{valueType, valueType}, logicalType},
+	"value":  {[]int{nodesType}, valueType},
+}
+
+type funcExpr struct {
+	name string
+	args []interface{} // comparand, *jsonPath or logicalExpr
+	re   *regexp.Regexp
+}
+
+// argValue evaluates a value type argument
+func (e *funcExpr) argValue(i int, ctx *queryContext, current interface{}) (interface{}, bool) {
+	switch arg := e.args[i].(type) {
+	case comparand:
+		return arg.compValue(ctx, current)
+	case *jsonPath:
+		return singularQueryExpr{arg}.compValue(ctx, current)
+	}
+	return nil, false
+}
+
+// argNodes evaluates a nodes type argument
+func (e *funcExpr) argNodes(i int, ctx *queryContext, current interface{}) []queryNode {
+	if q, ok := e.args[i].(*jsonPath); ok {
+		return q.eval(ctx, current)
+	}
+	return nil
+}
+
+func (e *funcExpr) compValue(ctx *queryContext, current interface{}) (interface{}, bool) {
+	switch e.name {
+	case "length":
+		v, ok := e.argValue(0, ctx, current)
+		if !ok {
+			return nil, false
+		}
+		switch cv := v.(type) {
+		case string:
+			return utf8.RuneCountInString(cv), true
+		case []interface{}:
+			return len(cv), true
+		case map[string]interface{}:
+			return len(cv), true
+		case *orderedObject:
+			return len(cv.values), true
+		}
+		return nil, false
+
+	case "count":
+		return len(e.argNodes(0, ctx, current)), true
+
+	case "value":
+		nodes := e.argNodes(0, ctx, current)
+		if len(nodes) != 1 {
+			return nil, false
+		}
+		return nodes[0].value, true
+	}
+	return nil, false
+}
+
+func (e *funcExpr) test(ctx *queryContext, current interface{}) bool {
+	v, ok := e.argValue(0, ctx, current)
+	s, isString := v.(string)
+	if !ok || !isString {
+		return false
+	}
+
+	re := e.re
+	if re == nil {
+		pattern, ok := e.argValue(1, ctx, current)
+		p, isString := pattern.(string)
+		if !ok || !isString {
+			return false
+		}
+		var err error
+		if re, err = compileQueryRegexp(e.name, p); err != nil {
+			return false
+		}
+	}
+	return re.MatchString(s)
+}
+
+// compileQueryRegexp compiles the pattern of a match() or search() function
+func compileQueryRegexp(name string, pattern string) (*regexp.Regexp, error) {
+	if name == "match" {
+		pattern = "^(?:" + pattern + ")$"
+	}
+	return regexp.Compile(pattern)
+}
+
+// queryParser is our JSONPath recursive descent parser
+type queryParser struct {
+	expr string
+	pos  int
+}
+
+// parseQuery compiles a JSONPath expression
+func parseQuery(expr string) (*jsonPath, error) {
+	p := &queryParser{expr: expr}
+	if p.peek() != '$' {
+		return nil, p.errorf("query must start with '$'")
+	}
+	q, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.expr) {
+		return nil, p.errorf("unexpected character %q", p.peek())
+	}
+	return q, nil
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return &QueryError{
+		Query:  p.expr,
+		Offset: p.pos,
+		msg:    fmt.Sprintf(format, args...),
+	}
+}
+
+func (p *queryParser) peek() byte {
+	if p.pos < len(p.expr) {
+		return p.expr[p.pos]
+	}
+	return 0
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.expr) {
+		switch p.expr[p.pos] {
+		case ' ', '\t', '\n', '\r':
+			p.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (p *queryParser) consume(s string) bool {
+	if strings.HasPrefix(p.expr[p.pos:], s) {
+		p.pos += len(s)
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) expect(c byte) error {
+	if p.peek() != c {
+		if p.pos >= len(p.expr) {
+			return p.errorf("expected '%c', got end of query", c)
+		}
+		return p.errorf("expected '%c', got %q", c, p.peek())
+	}
+	p.pos++
+	return nil
+}
+
+// parsePath parses a query starting by '$' or '@'
+func (p *queryParser) parsePath() (*jsonPath, error) {
+	q := &jsonPath{relative: p.peek() == '@'}
+	p.pos++
+
+	for {
+		save := p.pos
+		p.skipSpaces()
+
+		switch {
+		case p.consume(".."):
+			seg := &querySegment{descendant: true}
+			switch c := p.peek(); {
+			case c == '[':
+				sels, err := p.parseBracket()
+				if err != nil {
+					return nil, err
+				}
+				seg.selectors = sels
+			case c == '*':
+				p.pos++
+				seg.selectors = []selector{wildcardSelector{}}
+			case isNameFirst(c):
+				seg.selectors = []selector{nameSelector(p.parseName())}
+			default:
+				return nil, p.errorf("expected a name, '*' or '[' after '..'")
+			}
+			q.segments = append(q.segments, seg)
+
+		case p.consume("."):
+			seg := &querySegment{}
+			switch c := p.peek(); {
+			case c == '*':
+				p.pos++
+				seg.selectors = []selector{wildcardSelector{}}
+			case isNameFirst(c):
+				seg.selectors = []selector{nameSelector(p.parseName())}
+			default:
+				return nil, p.errorf("expected a name or '*' after '.'")
+			}
+			q.segments = append(q.segments, seg)
+
+		case p.peek() == '[':
+			sels, err := p.parseBracket()
+			if err != nil {
+				return nil, err
+			}
+			q.segments = append(q.segments, &querySegment{selectors: sels})
+
+		default:
+			p.pos = save
+			return q, nil
+		}
+	}
+}
+
+func isNameFirst(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c >= 0x80
+}
+
+func isNameChar(c byte) bool {
+	return isNameFirst(c) || c >= '0' && c <= '9'
+}
+
+func (p *queryParser) parseName() string {
+	start := p.pos
+	for p.pos < len(p.expr) && isNameChar(p.expr[p.pos]) {
+		p.pos++
+	}
+	return p.expr[start:p.pos]
+}
+
+// parseBracket parses a bracketed selection, ie ['a', 1, 2:5, ?@.b]
+func (p *queryParser) parseBracket() ([]selector, error) {
+	p.pos++ // [
+	var sels []selector
+	for {
+		p.skipSpaces()
+		sel, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		sels = append(sels, sel)
+		p.skipSpaces()
+		if p.peek() == ',' {
+			p.pos++
+			continue
+		}
+		if err := p.expect(']'); err != nil {
+			return nil, err
+		}
+		return sels, nil
+	}
+}
+
+func (p *queryParser) parseSelector() (selector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return nameSelector(s), nil
+
+	case c == '*':
+		p.pos++
+		return wildcardSelector{}, nil
+
+	case c == '?':
+		p.pos++
+		p.skipSpaces()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return filterSelector{expr}, nil
+
+	case c == '-' || c == ':' || c >= '0' && c <= '9':
+		return p.parseIndexOrSlice()
+	}
+
+	if p.pos >= len(p.expr) {
+		return nil, p.errorf("expected a selector, got end of query")
+	}
+	return nil, p.errorf("expected a selector, got %q", p.peek())
+}
+
+func (p *queryParser) parseIndexOrSlice() (selector, error) {
+	var bounds [3]*int
+	for i := 0; i < 3; i++ {
+		p.skipSpaces()
+		if c := p.peek(); c == '-' || c >= '0' && c <= '9' {
+			n, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			bounds[i] = &n
+			p.skipSpaces()
+		}
+
+		if i == 0 && p.peek() != ':' {
+			if bounds[0] == nil {
+				return nil, p.errorf("expected an index")
+			}
+			return indexSelector(*bounds[0]), nil
+		}
+		if i == 2 || p.peek() != ':' {
+			break
+		}
+		p.pos++
+	}
+
+	sel := sliceSelector{start: bounds[0], end: bounds[1], step: 1}
+	if bounds[2] != nil {
+		sel.step = *bounds[2]
+	}
+	return sel, nil
+}
+
+func (p *queryParser) parseInt() (int, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	digits := p.pos
+	for c := p.peek(); c >= '0' && c <= '9'; c = p.peek() {
+		p.pos++
+	}
+	s := p.expr[start:p.pos]
+	if p.pos == digits || (p.expr[digits] == '0' && p.pos-digits > 1) || s == "-0" {
+		p.pos = start
+		return 0, p.errorf("invalid integer '%s'", s)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n > maxQueryInt || n < -maxQueryInt {
+		p.pos = start
+		return 0, p.errorf("integer '%s' out of range", s)
+	}
+	return int(n), nil
+}
+
+// parseString parses a single or double quoted string literal
+func (p *queryParser) parseString() (string, error) {
+	quote := p.peek()
+	p.pos++
+	var sb strings.Builder
+	for {
+		if p.pos >= len(p.expr) {
+			return "", p.errorf("unterminated string")
+		}
+		c := p.expr[p.pos]
+		switch {
+		case c == quote:
+			p.pos++
+			return sb.String(), nil
+
+		case c < 0x20:
+			return "", p.errorf("invalid control character in string")
+
+		case c == '\\':
+			p.pos++
+			e := p.peek()
+			switch e {
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case '/', '\\':
+				sb.WriteByte(e)
+			case 'u':
+				r, err := p.parseUnicodeEscape()
+				if err != nil {
+					return "", err
+				}
+				sb.WriteRune(r)
+				continue
+			default:
+				if e != quote {
+					return "", p.errorf("invalid escape sequence '\\%c'", e)
+				}
+				sb.WriteByte(e)
+			}
+			p.pos++
+
+		default:
+			sb.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseUnicodeEscape parses \uXXXX sequences, including surrogate pairs
+// The parser is positioned on the 'u'
+func (p *queryParser) parseUnicodeEscape() (rune, error) {
+	hex := func() (rune, error) {
+		if p.pos+5 > len(p.expr) {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		n, err := strconv.ParseUint(p.expr[p.pos+1:p.pos+5], 16, 16)
+		if err != nil {
+			return 0, p.errorf("invalid unicode escape")
+		}
+		p.pos += 5
+		return rune(n), nil
+	}
+
+	r, err := hex()
+	if err != nil {
+		return 0, err
+	}
+	if utf16.IsSurrogate(r) {
+		if !p.consume("\\") || p.peek() != 'u' {
+			return 0, p.errorf("invalid unicode surrogate pair")
+		}
+		r2, err := hex()
+		if err != nil {
+			return 0, err
+		}
+		if r = utf16.DecodeRune(r, r2); r == utf8.RuneError {
+			return 0, p.errorf("invalid unicode surrogate pair")
+		}
+	}
+	return r, nil
+}
+
+func (p *queryParser) parseOr() (logicalExpr, error) {
+	var exprs orExpr
+	for {
+		expr, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		p.skipSpaces()
+		if !p.consume("||") {
+			break
+		}
+		p.skipSpaces()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *queryParser) parseAnd() (logicalExpr, error) {
+	var exprs andExpr
+	for {
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		p.skipSpaces()
+		if !p.consume("&&") {
+			break
+		}
+		p.skipSpaces()
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+// parseBasic parses a parenthesized, a comparison or a test expression
+func (p *queryParser) parseBasic() (logicalExpr, error) {
+	if p.peek() == '!' {
+		p.pos++
+		p.skipSpaces()
+		start := p.pos
+		expr, err := p.parseBasic()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := expr.(compareExpr); ok && p.expr[start] != '(' {
+			p.pos = start
+			return nil, p.errorf("'!' can't be applied to a comparison without parentheses")
+		}
+		return notExpr{expr}, nil
+	}
+
+	if p.peek() == '(' {
+		p.pos++
+		p.skipSpaces()
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if err := p.expect(')'); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	}
+
+	start := p.pos
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	save := p.pos
+	p.skipSpaces()
+	op := p.parseOperator()
+	if len(op) == 0 {
+		p.pos = save
+		switch operand := left.(type) {
+		case *jsonPath:
+			return existExpr{operand}, nil
+		case *funcExpr:
+			if queryFunctions[operand.name].result == logicalType {
+				return operand, nil
+			}
+			p.pos = start
+			return nil, p.errorf("function %s() result must be compared", operand.name)
+		}
+		p.pos = start
+		return nil, p.errorf("literal must be compared")
+	}
+
+	lc, err := p.toComparable(left, start)
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	rstart := p.pos
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	rc, err := p.toComparable(right, rstart)
+	if err != nil {
+		return nil, err
+	}
+	return compareExpr{op, lc, rc}, nil
+}
+
+func (p *queryParser) parseOperator() string {
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// toComparable checks an operand can be used in a comparison
+func (p *queryParser) toComparable(operand interface{}, start int) (comparand, error) {
+	switch o := operand.(type) {
+	case *jsonPath:
+		if !o.singular() {
+			p.pos = start
+			return nil, p.errorf("query in comparison must be singular")
+		}
+		return singularQueryExpr{o}, nil
+	case *funcExpr:
+		if queryFunctions[o.name].result != valueType {
+			p.pos = start
+			return nil, p.errorf("function %s() can't be compared", o.name)
+		}
+		return o, nil
+	case literalExpr:
+		return o, nil
+	}
+	return nil, p.errorf("invalid comparison operand")
+}
+
+// parseOperand parses a query, a function call or a literal
+func (p *queryParser) parseOperand() (interface{}, error) {
+	c := p.peek()
+	switch {
+	case c == '@' || c == '$':
+		return p.parsePath()
+
+	case c == '\'' || c == '"':
+		s, err := p.parseString()
+		if err != nil {
+			return nil, err
+		}
+		return literalExpr{s}, nil
+
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseNumber()
+
+	case c >= 'a' && c <= 'z':
+		start := p.pos
+		for c := p.peek(); c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_'; c = p.peek() {
+			p.pos++
+		}
+		name := p.expr[start:p.pos]
+		if p.peek() == '(' {
+			return p.parseFunction(name, start)
+		}
+		switch name {
+		case "true":
+			return literalExpr{true}, nil
+		case "false":
+			return literalExpr{false}, nil
+		case "null":
+			return literalExpr{nil}, nil
+		}
+		p.pos = start
+		return nil, p.errorf("unknown literal '%s'", name)
+	}
+
+	if p.pos >= len(p.expr) {
+		return nil, p.errorf("expected an expression, got end of query")
+	}
+	return nil, p.errorf("expected an expression, got %q", c)
+}
+
+func (p *queryParser) parseNumber() (interface{}, error) {
+	start := p.pos
+	if p.peek() == '-' {
+		p.pos++
+	}
+	for c := p.peek(); c >= '0' && c <= '9' || c == '.' || c == 'e' || c == 'E' || c == '+' || c == '-'; c = p.peek() {
+		p.pos++
+	}
+	s := p.expr[start:p.pos]
+	if !numberRegexp.MatchString(s) {
+		p.pos = start
+		return nil, p.errorf("invalid number '%s'", s)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(f, 0) {
+		p.pos = start
+		return nil, p.errorf("invalid number '%s'", s)
+	}
+	return literalExpr{f}, nil
+}
+
+func (p *queryParser) parseFunction(name string, start int) (interface{}, error) {
+	fn, ok := queryFunctions[name]
+	if !ok {
+		p.pos = start
+		return nil, p.errorf("unknown function '%s'", name)
+	}
+	p.pos++ // (
+
+	e := &funcExpr{name: name}
+	for i := range fn.params {
+		p.skipSpaces()
+		if i > 0 {
+			if err := p.expect(','); err != nil {
+				return nil, err
+			}
+			p.skipSpaces()
+		}
+
+		astart := p.pos
+		arg, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+
+		switch fn.params[i] {
+		case nodesType:
+			q, ok := arg.(*jsonPath)
+			if !ok {
+				p.pos = astart
+				return nil, p.errorf("function %s() expects a query argument", name)
+			}
+			e.args = append(e.args, q)
+		default:
+			c, err := p.toComparable(arg, astart)
+			if err != nil {
+				return nil, err
+			}
+			e.args = append(e.args, c)
+		}
+	}
+	p.skipSpaces()
+	if err := p.expect(')'); err != nil {
+		return nil, err
+	}
+
+	// Precompile literal patterns
+	if fn.result == logicalType {
+		if lit, ok := e.args[1].(literalExpr); ok {
+			pattern, ok := lit.value.(string)
+			if !ok {
+				p.pos = start
+				return nil, p.errorf("function %s() expects a string pattern", name)
+			}
+			re, err := compileQueryRegexp(name, pattern)
+			if err != nil {
+				p.pos = start
+				return nil, p.errorf("invalid pattern '%s' in %s()", pattern, name)
+			}
+			e.re = re
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,185 @@
+package jsonmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+// Example from RFC 9535
+const jsonStore = `
+{
+	"store": {
+		"book": [
+			{ "category": "reference", "author": "Nigel Rees", "title": "Sayings of the Century", "price": 8.95 },
+			{ "category": "fiction", "author": "Evelyn Waugh", "title": "Sword of Honour", "price": 12.99 },
+			{ "category": "fiction", "author": "Herman Melville", "title": "Moby Dick", "isbn": "0-553-21311-3", "price": 8.99 },
+			{ "category": "fiction", "author": "J. R. R. Tolkien", "title": "The Lord of the Rings", "isbn": "0-395-19395-8", "price": 22.99 }
+		],
+		"bicycle": { "color": "red", "price": 399 }
+	}
+}
+`
+
+func query(t *testing.T, j *jsonmap.Json, expr string) []interface{} {
+	nodes, err := j.Query(expr)
+	assert.NoError(t, err, expr)
+	values := make([]interface{}, len(nodes))
+	for i, n := range nodes {
+		values[i] = n.Data()
+	}
+	return values
+}
+
+func queryPaths(t *testing.T, j *jsonmap.Json, expr string) []string {
+	nodes, err := j.Query(expr)
+	assert.NoError(t, err, expr)
+	paths := make([]string, len(nodes))
+	for i, n := range nodes {
+		paths[i] = n.Path()
+	}
+	return paths
+}
+
+func TestQuery(t *testing.T) {
+	j := jsonmap.FromString(jsonStore)
+
+	assert.Equal(t, []interface{}{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}, query(t, j, "$.store.book[*].author"))
+	assert.Equal(t, []interface{}{"Nigel Rees", "Evelyn Waugh", "Herman Melville", "J. R. R. Tolkien"}, query(t, j, "$..author"))
+	assert.Equal(t, []interface{}{399.0, 8.95, 12.99, 8.99, 22.99}, query(t, j, "$.store..price"))
+	assert.Equal(t, []interface{}{"Moby Dick"}, query(t, j, "$..book[2].title"))
+	assert.Equal(t, []interface{}{"The Lord of the Rings"}, query(t, j, "$..book[-1].title"))
+	assert.Equal(t, []interface{}{"Sayings of the Century", "Sword of Honour"}, query(t, j, "$..book[0,1].title"))
+	assert.Equal(t, []interface{}{"Sayings of the Century", "Sword of Honour"}, query(t, j, "$..book[:2].title"))
+	assert.Equal(t, []interface{}{"Moby Dick", "The Lord of the Rings"}, query(t, j, "$..book[?@.isbn].title"))
+	assert.Equal(t, []interface{}{"Sayings of the Century", "Moby Dick"}, query(t, j, "$..book[?@.price<10].title"))
+	assert.Equal(t, []interface{}{"Sayings of the Century", "Moby Dick"}, query(t, j, "$..book[?(@.price < 10)].title"))
+	assert.Equal(t, []interface{}{"red"}, query(t, j, "$['store']['bicycle'][\"color\"]"))
+	assert.Len(t, query(t, j, "$..*"), 27)
+	assert.Len(t, query(t, j, "$.store.*"), 2)
+	assert.Empty(t, query(t, j, "$.unknown"))
+	assert.Equal(t, []interface{}{j.Data()}, query(t, j, "$"))
+}
+
+func TestQuerySlice(t *testing.T) {
+	j := jsonmap.FromString(`["a", "b", "c", "d", "e", "f", "g"]`)
+	assert.Equal(t, []interface{}{"b", "c"}, query(t, j, "$[1:3]"))
+	assert.Equal(t, []interface{}{"f", "g"}, query(t, j, "$[5:]"))
+	assert.Equal(t, []interface{}{"b", "d"}, query(t, j, "$[1:5:2]"))
+	assert.Equal(t, []interface{}{"f", "d"}, query(t, j, "$[5:1:-2]"))
+	assert.Equal(t, []interface{}{"g", "f", "e", "d", "c", "b", "a"}, query(t, j, "$[::-1]"))
+	assert.Equal(t, []interface{}{"e", "f"}, query(t, j, "$[-3:-1]"))
+	assert.Empty(t, query(t, j, "$[1:5:0]"))
+	assert.Empty(t, query(t, j, "$[10:]"))
+	assert.Equal(t, []interface{}{"a"}, query(t, j, "$[-7]"))
+	assert.Empty(t, query(t, j, "$[-8]"))
+}
+
+func TestQueryFilter(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"a": [3, 5, 1, 2, 4, 6, {"b": "j"}, {"b": "k"}, {"b": {}}, {"b": "kilo"}],
+		"o": {"p": 1, "q": 2, "r": 3, "s": 5, "t": {"u": 6}},
+		"e": "f"
+	}`)
+
+	assert.Equal(t, []interface{}{map[string]interface{}{"b": "kilo"}}, query(t, j, "$.a[?@.b == 'kilo']"))
+	assert.Equal(t, []interface{}{3.0, 5.0, 4.0, 6.0}, query(t, j, "$.a[?@>3.5 || @ == 3]"))
+	assert.Equal(t, []interface{}{map[string]interface{}{"b": map[string]interface{}{}}}, query(t, j, "$.a[?length(@.b) == 0]"))
+	assert.Equal(t, []interface{}{3.0, 5.0, 1.0, 2.0, 4.0, 6.0}, query(t, j, "$.a[?!@.b]"))
+	assert.Equal(t, []interface{}{1.0, 2.0, 3.0}, query(t, j, "$.o[?@<3 || @==3]"))
+	assert.Equal(t, []interface{}{1.0, 2.0, 3.0, 5.0}, query(t, j, "$.o[?@ >= 1 && @ <= 5]"))
+	assert.Equal(t, []interface{}{3.0, 5.0, 1.0, 2.0, 4.0, 6.0}, query(t, j, "$.a[?!(@.b)]"))
+	assert.Equal(t, []interface{}{"j", "k", "kilo"}, query(t, j, "$.a[?@.b != null && match(@.b, 'k.*|j')].b"))
+	assert.Equal(t, []interface{}{"k", "kilo"}, query(t, j, "$.a[?search(@.b, 'k')].b"))
+	assert.Equal(t, []interface{}{"k"}, query(t, j, "$.a[?match(@.b, 'k')].b"))
+	assert.Equal(t, []interface{}{map[string]interface{}{"u": 6.0}}, query(t, j, "$.o[?count(@.*) == 1]"))
+	assert.Equal(t, []interface{}{map[string]interface{}{"u": 6.0}}, query(t, j, "$.o[?value(@..u) == 6]"))
+	assert.Equal(t, []interface{}{"kilo"}, query(t, j, "$.a[?length(@.b) > 3].b"))
+	assert.Equal(t, []interface{}{5.0}, query(t, j, "$.a[?@ == $.o.s]"))
+	assert.Len(t, query(t, j, "$.a[?$.e == 'f']"), 10)
+	assert.Equal(t, []interface{}{"j"}, query(t, j, "$.a[?@.b == \"\\u006a\"].b"))
+	assert.Equal(t, []interface{}{1.0}, query(t, j, "$.o[?@ == 1.0e0]"))
+	assert.Equal(t, []interface{}{2.0, 3.0, 5.0}, query(t, j, "$.o[?!(@ == 1 || @.u)]"))
+
+	// big numbers are compared exactly
+	big, _ := jsonmap.ParseString(`[9007199254740992, 9007199254740993, 9007199254740994]`, jsonmap.UseNumber(true))
+	for expr, expected := range map[string][]string{
+		"$[?@ == 9007199254740992]": {"[0]"},
+		"$[?@ != 9007199254740992]": {"[1]", "[2]"},
+		"$[?@ < 9007199254740992]":  {},
+		"$[?@ <= 9007199254740992]": {"[0]"},
+		"$[?@ > 9007199254740992]":  {"[1]", "[2]"},
+		"$[?@ >= 9007199254740992]": {"[0]", "[1]", "[2]"},
+		"$[?@ > $[1]]":              {"[2]"},
+		"$[?@ < $[1]]":              {"[0]"},
+	} {
+		assert.Equal(t, expected, queryPaths(t, big, expr), expr)
+	}
+}
+
+func TestQueryElasticsearch(t *testing.T) {
+	j := jsonmap.FromString(`{
+		"aggregations": {
+			"by_day": {
+				"buckets": [
+					{ "key": "monday", "doc_count": 12, "by_host": { "buckets": [{ "key": "a", "doc_count": 11 }, { "key": "b", "doc_count": 1 }] } },
+					{ "key": "tuesday", "doc_count": 5, "by_host": { "buckets": [{ "key": "c", "doc_count": 5 }] } }
+				]
+			}
+		}
+	}`)
+
+	assert.Equal(t, []interface{}{"monday"}, query(t, j, "$.aggregations.by_day.buckets[?(@.doc_count > 10)].key"))
+	assert.Equal(t, []interface{}{"monday", "tuesday", "a", "b", "c"}, query(t, j, "$..buckets[*].key"))
+	assert.Equal(t, []string{"aggregations.by_day.buckets[0].by_host.buckets[0].key"}, queryPaths(t, j, "$..by_host.buckets[?@.doc_count > 10].key"))
+
+	// Paths can be used with Get
+	for _, p := range queryPaths(t, j, "$..buckets[*].doc_count") {
+		assert.False(t, j.Get(p).IsNil(), p)
+	}
+
+	// Relative to a sub json
+	sub := j.Get("aggregations.by_day")
+	assert.Equal(t, []string{"aggregations.by_day.buckets[1].key"}, queryPaths(t, sub, "$.buckets[-1].key"))
+}
+
+func TestQueryError(t *testing.T) {
+	j := jsonmap.FromString(jsonStore)
+
+	for expr, offset := range map[string]int{
+		"store":                  0,
+		"$.":                     2,
+		"$[":                     2,
+		"$['a'":                  5,
+		"$['a":                   4,
+		"$[01]":                  2,
+		"$[-0]":                  2,
+		"$[9007199254740992]":    2,
+		"$.a ":                   3,
+		"$[?@.a == ]":            10,
+		"$[?@.a = 1]":            7,
+		"$[?@.* == 1]":           3,
+		"$[?@.a == 1 &&]":        14,
+		"$[?foo(@.a)]":           3,
+		"$[?length(@.a)]":        3,
+		"$[?count(1) == 1]":      9,
+		"$[?match(@.a, '[') ]":   3,
+		"$[?!@.a == 1]":          4,
+		"$[?1]":                  3,
+		"$[?truthy]":             3,
+		"$['\\q']":               4,
+		"$[?@.a == 'a' || (@.b]": 21,
+		"$[?@.a == '\\ud800x']":  17,
+		"$[1:2:3:4]":             7,
+		"$..":                    3,
+	} {
+		_, err := j.Query(expr)
+		var qe *jsonmap.QueryError
+		if assert.True(t, errors.As(err, &qe), expr) {
+			assert.Equal(t, expr, qe.Query)
+			assert.Equal(t, offset, qe.Offset, expr)
+		}
+	}
+}
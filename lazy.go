@@ -0,0 +1,244 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// FromBytesLazy creates a Json decoding bytes on demand : Get and Has only decode the values at their path,
+// the whole json is decoded on first use of any other method, ie ForEach or Stringify, or on first modification
+// of the json or of a value got from it, ie Set, so modifications are shared as with FromBytes.
+// Useful to read a few values out of a large response, ie FromBytesLazy(resp).Get("hits.total.value").AsInt()
+// Returns a nil Json if bytes are not a valid json.
+// bytes are kept without copy and must not be modified. Until the json is decoded, the objects and arrays
+// read from its values, ie with AsObject or Values, are copies, and it is not safe for concurrent use, even read-only
+func FromBytesLazy(bytes []byte) *Json {
+	if !json.Valid(bytes) {
+		return Nil()
+	}
+	l := &lazyData{raw: bytes, opts: defaultParseOptions}
+	l.root = l
+	return &Json{lazy: l}
+}
+
+// lazyData is the undecoded input of a lazy json, see FromBytesLazy
+// It is shared by the copies of the json, ie Coerce(), which share the decoded data
+type lazyData struct {
+	root     *lazyData            // input of the root json, itself for the root
+	keys     []pathKey            // location in the root
+	children map[string]*lazyData // by path, see get
+	raw      []byte
+	opts     ParseOptions
+	data     interface{}
+	decoded  bool
+}
+
+// decode decodes the input once, the input of the root is then released
+func (l *lazyData) decode() interface{} {
+	if !l.decoded {
+		l.data, _ = decode(l.raw, l.opts)
+		l.decoded = true
+		if l.root == l {
+			l.raw, l.children = nil, nil
+		}
+	}
+	return l.data
+}
+
+// load decodes the input of a lazy json, it must be called before reading j.data
+// A value got from a lazy json is decoded alone until its root is decoded, see modify
+func (j *Json) load() {
+	if j == nil || j.lazy == nil {
+		return
+	}
+	l := j.lazy
+	switch {
+	case l.root == l:
+		j.data, j.lazy = l.decode(), nil
+	case l.root.decoded:
+		j.data, _ = getValue(l.root.data, l.keys)
+		j.lazy = nil
+	default:
+		j.data = l.decode()
+	}
+}
+
+// modify decodes the root of a lazy json, it must be called before modifying j.data
+// so the modification is applied in the root
+func (j *Json) modify() {
+	if j != nil && j.lazy != nil {
+		j.lazy.root.decode()
+	}
+	j.load()
+}
+
+// lazyGet gets the undecoded value at path, nil if not found
+// Returns false if the path can't be resolved without decoding, ie with wildcards
+func (j *Json) lazyGet(path string, keys []pathKey) (*lazyData, bool) {
+	if j.lazy == nil || j.lazy.root.decoded {
+		return nil, false
+	}
+	if c, ok := j.lazy.children[path]; ok {
+		return c, true
+	}
+	raw, ok := j.lazyValue(keys)
+	if !ok {
+		return nil, false
+	}
+	var c *lazyData
+	if raw != nil {
+		l := j.lazy
+		c = &lazyData{root: l.root, raw: raw, opts: l.opts}
+		c.keys = append(append(make([]pathKey, 0, len(l.keys)+len(keys)), l.keys...), keys...)
+	}
+	if j.lazy.children == nil {
+		j.lazy.children = make(map[string]*lazyData)
+	}
+	j.lazy.children[path] = c
+	return c, true
+}
+
+// lazyValue returns the undecoded value at keys, nil if not found
+// Returns false if keys can't be resolved without decoding, ie with wildcards
+func (j *Json) lazyValue(keys []pathKey) ([]byte, bool) {
+	if j.lazy == nil || j.lazy.root.decoded {
+		return nil, false
+	}
+	for _, k := range keys {
+		if k.wildcard || k.append {
+			return nil, false
+		}
+	}
+	raw, _ := rawValue(j.lazy.raw, keys)
+	return raw, true
+}
+
+// rawValue returns the value at keys in data, which is a valid json
+func rawValue(data []byte, keys []pathKey) ([]byte, bool) {
+	i := skipRawSpaces(data, 0)
+	for _, k := range keys {
+		var ok bool
+		switch data[i] {
+		case '{':
+			i, ok = rawMember(data, i, k.name)
+		case '[':
+			i, ok = rawItem(data, i, k)
+		}
+		if !ok {
+			return nil, false
+		}
+	}
+	return data[i:skipRawValue(data, i)], true
+}
+
+// rawMember returns the offset of the value of the member name of the object at i
+// The last member wins if a name is duplicated, as when decoding
+func rawMember(data []byte, i int, name string) (int, bool) {
+	found, ok := 0, false
+	i = skipRawSpaces(data, i+1)
+	for data[i] != '}' {
+		end := skipRawValue(data, i)
+		matched := rawKeyEqual(data[i:end], name)
+		i = skipRawSpaces(data, skipRawSpaces(data, end)+1) // skips ':'
+		if matched {
+			found, ok = i, true
+		}
+		i = skipRawSpaces(data, skipRawValue(data, i))
+		if data[i] == ',' {
+			i = skipRawSpaces(data, i+1)
+		}
+	}
+	return found, ok
+}
+
+// rawItem returns the offset of the item at k of the array at i
+func rawItem(data []byte, i int, k pathKey) (int, bool) {
+	idx := k.index
+	if k.isIndex && idx < 0 {
+		idx += rawLen(data, i)
+	}
+	if !k.isIndex || idx < 0 {
+		return 0, false
+	}
+	i = skipRawSpaces(data, i+1)
+	for n := 0; data[i] != ']'; n++ {
+		if n == idx {
+			return i, true
+		}
+		i = skipRawSpaces(data, skipRawValue(data, i))
+		if data[i] == ',' {
+			i = skipRawSpaces(data, i+1)
+		}
+	}
+	return 0, false
+}
+
+// rawLen returns the number of items of the array at i
+func rawLen(data []byte, i int) int {
+	n := 0
+	i = skipRawSpaces(data, i+1)
+	for data[i] != ']' {
+		n++
+		i = skipRawSpaces(data, skipRawValue(data, i))
+		if data[i] == ',' {
+			i = skipRawSpaces(data, i+1)
+		}
+	}
+	return n
+}
+
+// rawKeyEqual checks if a quoted object key is name
+func rawKeyEqual(key []byte, name string) bool {
+	if bytes.IndexByte(key, '\\') < 0 {
+		return string(key[1:len(key)-1]) == name
+	}
+	var s string
+	return json.Unmarshal(key, &s) == nil && s == name
+}
+
+// skipRawSpaces returns the offset of the first byte which is not a whitespace from i
+func skipRawSpaces(data []byte, i int) int {
+	for i < len(data) && isSpace(data[i]) {
+		i++
+	}
+	return i
+}
+
+// skipRawValue returns the offset after the value at i
+func skipRawValue(data []byte, i int) int {
+	switch data[i] {
+	case '{', '[':
+		depth := 0
+		for ; i < len(data); i++ {
+			switch data[i] {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+				if depth == 0 {
+					return i + 1
+				}
+			case '"':
+				i = skipRawValue(data, i) - 1
+			}
+		}
+		return i
+
+	case '"':
+		for i++; i < len(data); i++ {
+			switch data[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
+		}
+		return i
+
+	default:
+		for i < len(data) && !isSpace(data[i]) && !isDelimiter(data[i]) {
+			i++
+		}
+		return i
+	}
+}
@@ -0,0 +1,119 @@
+package jsonmap_test
+
+import (
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+const lazyTest = `{
+	"took": 5,
+	"hits": {
+		"total": { "value": 2, "relation": "eq" },
+		"hits": [
+			{ "_id": "1", "_source": { "name": "john", "tags": ["a", "b"] } },
+			{ "_id": "2", "_source": { "name": "jane \"jj\"", "tags": [] } }
+		]
+	},
+	"a\"b": { "c.d": null },
+	"dup": 1,
+	"dup": 2
+}`
+
+func TestFromBytesLazy(t *testing.T) {
+	j := jsonmap.FromBytesLazy([]byte(lazyTest))
+	assert.Equal(t, int64(5), j.Get("took").AsInt())
+	assert.Equal(t, int64(2), j.Get("hits.total.value").AsInt())
+	assert.Equal(t, "eq", j.Get("hits").Get("total").Get("relation").AsString())
+	assert.Equal(t, "2", j.Get("hits.hits[-1]._id").AsString())
+	assert.Equal(t, `jane "jj"`, j.Get("hits.hits[1]._source.name").AsString())
+	assert.Equal(t, []interface{}{"a", "b"}, j.Get("hits.hits[0]._source.tags").AsArray())
+	assert.Equal(t, "hits.hits[0]._source.tags", j.Get("hits.hits[0]._source.tags").Path())
+	assert.Equal(t, int64(2), j.Get("dup").AsInt())
+	assert.True(t, j.Get("hits.hits[2]").IsNil())
+	assert.True(t, j.Get("took.value").IsNil())
+	assert.True(t, j.Get("hits.total[0]").IsNil())
+
+	assert.True(t, j.Has("hits.hits[0]._source"))
+	assert.True(t, j.Has(`a"b`))
+	assert.False(t, j.Has(`a"b.c\.d`))
+	assert.False(t, j.Has("hits.missing"))
+
+	// wildcards decode the json
+	assert.Equal(t, "john", j.Get("hits.hits[*]._source.name").AsString())
+	assert.Equal(t, int64(2), j.Get("hits.total.value").AsInt())
+}
+
+func TestFromBytesLazySet(t *testing.T) {
+	j := jsonmap.FromBytesLazy([]byte(lazyTest))
+	c := j.Coerce()
+	assert.Equal(t, int64(2), c.Get("hits.total.relation").AsIntOr(2))
+	assert.True(t, j.Set("hits.total.value", 3))
+	assert.Equal(t, int64(3), j.Get("hits.total.value").AsInt())
+
+	// copies share the decoded data
+	assert.Equal(t, int64(3), c.Get("hits.total.value").AsInt())
+	assert.Equal(t, jsonmap.FromString(lazyTest).Get("hits.hits").Stringify(), j.Get("hits.hits").Stringify())
+
+	// modifying a value decodes the root, as with FromBytes
+	for _, from := range []func([]byte) *jsonmap.Json{jsonmap.FromBytes, jsonmap.FromBytesLazy} {
+		j = from([]byte(lazyTest))
+		total := j.Get("hits.total")
+		assert.Equal(t, "eq", total.Get("relation").AsString())
+		hit := j.Get("hits").Get("hits[1]")
+		assert.Equal(t, "2", hit.Get("_id").AsString())
+
+		assert.True(t, total.Set("value", 4))
+		assert.Equal(t, int64(4), j.Get("hits.total.value").AsInt())
+		assert.Equal(t, int64(4), total.Get("value").AsInt())
+
+		assert.True(t, hit.Set("_source.tags[]", "c"))
+		assert.True(t, j.Set("hits.hits[1]._id", "3"))
+		assert.Equal(t, "3", hit.Get("_id").AsString())
+		assert.Equal(t, `["c"]`, j.Get("hits.hits[1]._source.tags").Stringify())
+		assert.True(t, j.Get("hits.hits[0]._source").Unset("tags"))
+		assert.False(t, j.Has("hits.hits[0]._source.tags"))
+	}
+}
+
+func TestFromBytesLazyCache(t *testing.T) {
+	j := jsonmap.FromBytesLazy([]byte(lazyTest))
+	assert.Equal(t, float64(1), testing.AllocsPerRun(100, func() { j.Get("hits.total.value").AsInt() }))
+	assert.True(t, j.Get("hits.missing").IsNil())
+	assert.True(t, j.Get("hits.missing").IsNil())
+}
+
+func TestFromBytesLazyRoot(t *testing.T) {
+	j := jsonmap.FromBytesLazy([]byte(` [1, 2, 3] `))
+	assert.Equal(t, jsonmap.ArrayKind, j.Kind())
+	assert.Equal(t, int64(3), j.Get("[2]").AsInt())
+	assert.Equal(t, `[1,2,3]`, j.Stringify())
+
+	assert.Equal(t, "john", jsonmap.FromBytesLazy([]byte(`"john"`)).AsString())
+	assert.True(t, jsonmap.FromBytesLazy([]byte(`{ "a": `)).IsNil())
+	assert.True(t, jsonmap.FromBytesLazy(nil).IsNil())
+
+	var v struct {
+		Name string `json:"name"`
+	}
+	assert.NoError(t, jsonmap.FromBytesLazy([]byte(`{ "name": "john" }`)).Decode(&v))
+	assert.Equal(t, "john", v.Name)
+	assert.True(t, jsonmap.Equal(jsonmap.FromString(lazyTest), jsonmap.FromBytesLazy([]byte(lazyTest))))
+}
+
+func BenchmarkFromBytesLazy(b *testing.B) {
+	data := aggregationResponse().Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonmap.FromBytesLazy(data).Get("aggregations.terms.buckets[-1].doc_count").AsInt()
+	}
+}
+
+func BenchmarkFromBytes(b *testing.B) {
+	data := aggregationResponse().Bytes()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonmap.FromBytes(data).Get("aggregations.terms.buckets[-1].doc_count").AsInt()
+	}
+}
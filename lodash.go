@@ -48,8 +48,8 @@ func Assign(source *Json, json ...*Json) *Json {
 	for _, j := range json {
 		if !IsNil(j) {
 			if o := j.AsObject(); o != nil {
-				for k, v := range o {
-					source.Set(k, v)
+				for _, k := range j.Keys() {
+					source.Set(k, o[k])
 				}
 			}
 		}
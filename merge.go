@@ -0,0 +1,183 @@
+package jsonmap
+
+import (
+	"fmt"
+)
+
+// ArrayStrategy defines how DeepMerge combines two arrays
+type ArrayStrategy uint8
+
+// Array strategies
+const (
+	ArrayReplace      ArrayStrategy = iota // the last array replaces the previous one
+	ArrayConcat                            // items are appended
+	ArrayUnion                             // items are appended if not already present, see MergeOptions.ArrayKey
+	ArrayMergeByIndex                      // items with the same index are deeply merged
+)
+
+// ScalarStrategy defines how DeepMerge resolves a conflict between two different values
+// which are not both objects or both arrays
+type ScalarStrategy uint8
+
+// Scalar strategies
+const (
+	LastWins        ScalarStrategy = iota // the last value is kept
+	FirstWins                             // the first value is kept
+	ErrorOnConflict                       // DeepMerge returns a *MergeError
+)
+
+// MergeOptions are our DeepMerge options
+type MergeOptions struct {
+	Arrays  ArrayStrategy
+	Scalars ScalarStrategy
+
+	// ArrayKey is the path of the key identifying array items with ArrayUnion, ie "name".
+	// Items with the same key are deeply merged. When empty, items are compared by value
+	ArrayKey string
+}
+
+// MergeError is returned by DeepMerge on conflicting values with ErrorOnConflict
+type MergeError struct {
+	Path string // path of the conflicting values
+}
+
+// Error implements the error interface
+func (e *MergeError) Error() string {
+	return fmt.Sprintf("jsonmap: merge conflict at '%s'", e.Path)
+}
+
+// DeepMerge recursively merges multiples JSON into a new one.
+// Objects are merged key by key, arrays and other values according to opts.
+// Nil jsons are skipped and inputs are never modified.
+// Example : DeepMerge(nil, defaults, tenant, request)
+func DeepMerge(opts *MergeOptions, jsons ...*Json) (*Json, error) {
+	if opts == nil {
+		opts = &MergeOptions{}
+	}
+
+	var res interface{}
+	first := true
+	for _, j := range jsons {
+		if IsNil(j) {
+			continue
+		}
+		if first {
+			res = cloneValue(dataOf(j))
+			first = false
+			continue
+		}
+		merged, err := mergeValues(res, dataOf(j), "", opts)
+		if err != nil {
+			return nil, err
+		}
+		res = merged
+	}
+
+	if first {
+		return New(), nil
+	}
+	return &Json{data: res}, nil
+}
+
+// mergeValues merges src into dst which can be modified
+func mergeValues(dst interface{}, src interface{}, path string, opts *MergeOptions) (interface{}, error) {
+	if d, ok := objectOf(dst); ok {
+		if s, ok := objectOf(src); ok {
+			for _, k := range objectKeys(src) {
+				dv, exists := d[k]
+				if !exists {
+					setMember(dst, k, cloneValue(s[k]))
+					continue
+				}
+				merged, err := mergeValues(dv, s[k], keyPath(path, k), opts)
+				if err != nil {
+					return nil, err
+				}
+				d[k] = merged
+			}
+			return dst, nil
+		}
+	}
+
+	if d, ok := dst.([]interface{}); ok {
+		if s, ok := src.([]interface{}); ok {
+			return mergeArrays(d, s, path, opts)
+		}
+	}
+
+	if valuesEqual(dst, src) {
+		return dst, nil
+	}
+	switch opts.Scalars {
+	case FirstWins:
+		return dst, nil
+	case ErrorOnConflict:
+		return nil, &MergeError{Path: path}
+	default:
+		return cloneValue(src), nil
+	}
+}
+
+// mergeArrays merges src into dst according to opts.Arrays
+func mergeArrays(dst []interface{}, src []interface{}, path string, opts *MergeOptions) (interface{}, error) {
+	switch opts.Arrays {
+	case ArrayConcat:
+		for _, item := range src {
+			dst = append(dst, cloneValue(item))
+		}
+		return dst, nil
+
+	case ArrayUnion:
+		keys := pathKeys(opts.ArrayKey)
+		for _, item := range src {
+			merged := false
+			for i, d := range dst {
+				if !sameItem(d, item, keys) {
+					continue
+				}
+				m, err := mergeValues(d, item, indexPath(path, i), opts)
+				if err != nil {
+					return nil, err
+				}
+				dst[i] = m
+				merged = true
+				break
+			}
+			if !merged {
+				dst = append(dst, cloneValue(item))
+			}
+		}
+		return dst, nil
+
+	case ArrayMergeByIndex:
+		for i, item := range src {
+			if i >= len(dst) {
+				dst = append(dst, cloneValue(item))
+				continue
+			}
+			m, err := mergeValues(dst[i], item, indexPath(path, i), opts)
+			if err != nil {
+				return nil, err
+			}
+			dst[i] = m
+		}
+		return dst, nil
+
+	default:
+		return cloneValue(src), nil
+	}
+}
+
+// sameItem checks if two array items are identified by the same key
+// Without key, items are compared by value
+func sameItem(a interface{}, b interface{}, keys []pathKey) bool {
+	if len(keys) == 0 {
+		return valuesEqual(a, b)
+	}
+	ka, ok := getValue(a, keys)
+	if !ok || ka == nil {
+		return false
+	}
+	kb, ok := getValue(b, keys)
+	return ok && valuesEqual(ka, kb)
+}
@@ -0,0 +1,137 @@
+package jsonmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonDefaults = `
+{
+	"settings": {
+		"index": { "number_of_shards": 1, "refresh_interval": "1s" },
+		"analysis": { "analyzer": ["standard"] }
+	},
+	"mappings": [{ "name": "title", "type": "text" }, { "name": "date", "type": "date" }]
+}
+`
+
+const jsonTenant = `
+{
+	"settings": {
+		"index": { "number_of_shards": 3 },
+		"analysis": { "analyzer": ["french"] }
+	},
+	"mappings": [{ "name": "date", "format": "epoch_millis" }, { "name": "tenant", "type": "keyword" }]
+}
+`
+
+func TestDeepMerge(t *testing.T) {
+	defaults := jsonmap.FromString(jsonDefaults)
+	tenant := jsonmap.FromString(jsonTenant)
+	request := jsonmap.FromString(`{ "settings": { "index": { "refresh_interval": "30s" } } }`)
+
+	merge, err := jsonmap.DeepMerge(nil, defaults, tenant, nil, jsonmap.Nil(), request)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"settings": {
+			"index": { "number_of_shards": 3, "refresh_interval": "30s" },
+			"analysis": { "analyzer": ["french"] }
+		},
+		"mappings": [{ "name": "date", "format": "epoch_millis" }, { "name": "tenant", "type": "keyword" }]
+	}`, merge.Stringify())
+
+	// inputs are not modified
+	assert.JSONEq(t, jsonDefaults, defaults.Stringify())
+	assert.JSONEq(t, jsonTenant, tenant.Stringify())
+	merge.Set("mappings[0].name", "modified")
+	assert.Equal(t, "date", tenant.Get("mappings[0].name").AsString())
+
+	empty, err := jsonmap.DeepMerge(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", empty.Stringify())
+
+	value, err := jsonmap.DeepMerge(nil, jsonmap.FromString(`{ "a": 1 }`), jsonmap.FromString(`3`))
+	assert.NoError(t, err)
+	assert.Equal(t, "3", value.Stringify())
+}
+
+func TestDeepMergeArrays(t *testing.T) {
+	defaults := jsonmap.FromString(jsonDefaults)
+	tenant := jsonmap.FromString(jsonTenant)
+
+	t.Run("concat", func(t *testing.T) {
+		merge, err := jsonmap.DeepMerge(&jsonmap.MergeOptions{Arrays: jsonmap.ArrayConcat}, defaults, tenant)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `["standard", "french"]`, merge.Get("settings.analysis.analyzer").Stringify())
+		assert.Len(t, merge.Get("mappings").AsArray(), 4)
+	})
+
+	t.Run("union by key", func(t *testing.T) {
+		merge, err := jsonmap.DeepMerge(&jsonmap.MergeOptions{Arrays: jsonmap.ArrayUnion, ArrayKey: "name"}, defaults, tenant)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{ "name": "title", "type": "text" },
+			{ "name": "date", "type": "date", "format": "epoch_millis" },
+			{ "name": "tenant", "type": "keyword" }
+		]`, merge.Get("mappings").Stringify())
+		assert.JSONEq(t, `["standard", "french"]`, merge.Get("settings.analysis.analyzer").Stringify())
+	})
+
+	t.Run("union by value", func(t *testing.T) {
+		merge, err := jsonmap.DeepMerge(
+			&jsonmap.MergeOptions{Arrays: jsonmap.ArrayUnion},
+			jsonmap.FromString(`{ "tags": ["a", "b", 1] }`),
+			jsonmap.FromString(`{ "tags": ["b", "c", 1.0] }`),
+		)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{ "tags": ["a", "b", 1, "c"] }`, merge.Stringify())
+	})
+
+	t.Run("merge by index", func(t *testing.T) {
+		merge, err := jsonmap.DeepMerge(&jsonmap.MergeOptions{Arrays: jsonmap.ArrayMergeByIndex}, defaults, tenant)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[
+			{ "name": "date", "type": "text", "format": "epoch_millis" },
+			{ "name": "tenant", "type": "keyword" }
+		]`, merge.Get("mappings").Stringify())
+	})
+}
+
+func TestDeepMergeScalars(t *testing.T) {
+	defaults := jsonmap.FromString(jsonDefaults)
+	tenant := jsonmap.FromString(jsonTenant)
+
+	t.Run("first wins", func(t *testing.T) {
+		merge, err := jsonmap.DeepMerge(&jsonmap.MergeOptions{Scalars: jsonmap.FirstWins, Arrays: jsonmap.ArrayMergeByIndex}, defaults, tenant)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), merge.Get("settings.index.number_of_shards").AsInt())
+		assert.Equal(t, "1s", merge.Get("settings.index.refresh_interval").AsString())
+		assert.JSONEq(t, `[
+			{ "name": "title", "type": "text", "format": "epoch_millis" },
+			{ "name": "date", "type": "date" }
+		]`, merge.Get("mappings").Stringify())
+	})
+
+	t.Run("error on conflict", func(t *testing.T) {
+		opts := &jsonmap.MergeOptions{Scalars: jsonmap.ErrorOnConflict, Arrays: jsonmap.ArrayUnion, ArrayKey: "name"}
+
+		_, err := jsonmap.DeepMerge(opts, defaults, tenant)
+		var me *jsonmap.MergeError
+		assert.True(t, errors.As(err, &me))
+		assert.Equal(t, "settings.index.number_of_shards", me.Path)
+		assert.EqualError(t, err, "jsonmap: merge conflict at 'settings.index.number_of_shards'")
+
+		_, err = jsonmap.DeepMerge(opts, jsonmap.FromString(`{ "a": [{ "name": "x", "v": 1 }] }`), jsonmap.FromString(`{ "a": [{ "name": "x", "v": 2 }] }`))
+		assert.EqualError(t, err, "jsonmap: merge conflict at 'a[0].v'")
+
+		merge, err := jsonmap.DeepMerge(opts, jsonmap.FromString(`{ "a": 1, "b": { "c": true } }`), jsonmap.FromString(`{ "a": 1.0, "b": { "c": true, "d": null } }`))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{ "a": 1, "b": { "c": true, "d": null } }`, merge.Stringify())
+
+		_, err = jsonmap.DeepMerge(opts, jsonmap.FromString(`{ "a": { "b": 1 } }`), jsonmap.FromString(`{ "a": [1] }`))
+		assert.EqualError(t, err, "jsonmap: merge conflict at 'a'")
+	})
+}
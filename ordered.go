@@ -0,0 +1,305 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+// orderedObject is an object keeping the insertion order of its keys, see Ordered
+type orderedObject struct {
+	keys   []string
+	index  map[string]int // position of the keys, maintained by set, delete and rename
+	values map[string]interface{}
+}
+
+func newOrderedObject(size int) *orderedObject {
+	return &orderedObject{
+		keys:   make([]string, 0, size),
+		index:  make(map[string]int, size),
+		values: make(map[string]interface{}, size),
+	}
+}
+
+// NewOrdered creates an empty object Json keeping the insertion order of its keys, ie {}
+// Objects created by Set in an ordered object are also ordered
+func NewOrdered() *Json {
+	return &Json{data: newOrderedObject(0)}
+}
+
+// Ordered parses objects keeping the order of their keys.
+// The order is kept by Keys, ForEach, Set, Unset, Rewrite, Merge and Stringify,
+// new keys are added at the end
+// default : false
+func Ordered(v bool) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.Ordered = v
+	}
+}
+
+// IsOrdered checks if the current Json is an object keeping the order of its keys,
+// or an array of such objects
+func (j *Json) IsOrdered() bool {
+	j.load()
+	return isOrdered(j.data)
+}
+
+// ordered returns the keys in insertion order
+// Keys added directly in the values map (ie through AsObject) are added at the end.
+// It only reads o, so concurrent readers are safe : the order is repaired by set, delete and rename
+func (o *orderedObject) ordered() []string {
+	if o.synced() {
+		return o.keys
+	}
+
+	keys := make([]string, 0, len(o.values))
+	known := make(map[string]bool, len(o.keys))
+	for _, k := range o.keys {
+		if _, ok := o.values[k]; ok && !known[k] {
+			keys = append(keys, k)
+			known[k] = true
+		}
+	}
+	added := len(keys)
+	for k := range o.values {
+		if !known[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys[added:])
+	return keys
+}
+
+// synced checks if the keys are the keys of the values map, which can be modified through AsObject
+func (o *orderedObject) synced() bool {
+	if len(o.keys) != len(o.values) {
+		return false
+	}
+	for _, k := range o.keys {
+		if _, ok := o.values[k]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sync repairs the keys when members were added or deleted in the values map.
+// It only compares the lengths, so inserting a key doesn't rescan the keys
+func (o *orderedObject) sync() {
+	if len(o.keys) != len(o.values) {
+		o.reindex(o.ordered())
+	}
+}
+
+// reindex sets the keys and their positions
+func (o *orderedObject) reindex(keys []string) {
+	o.keys = keys
+	o.index = make(map[string]int, len(keys))
+	for i, k := range keys {
+		o.index[k] = i
+	}
+}
+
+// set sets a member, a new key is added at the end
+func (o *orderedObject) set(k string, v interface{}) {
+	o.sync()
+	if _, ok := o.index[k]; !ok {
+		o.index[k] = len(o.keys)
+		o.keys = append(o.keys, k)
+	}
+	o.values[k] = v
+}
+
+// delete removes a key, keys are copied so they can be iterated while deleting
+func (o *orderedObject) delete(k string) {
+	if _, ok := o.values[k]; !ok {
+		return
+	}
+	o.sync()
+	delete(o.values, k)
+	p, ok := o.index[k]
+	if !ok {
+		return
+	}
+	keys := make([]string, 0, len(o.keys)-1)
+	keys = append(append(keys, o.keys[:p]...), o.keys[p+1:]...)
+	delete(o.index, k)
+	for i := p; i < len(keys); i++ {
+		o.index[keys[i]] = i
+	}
+	o.keys = keys
+}
+
+// rename renames a key keeping its position
+func (o *orderedObject) rename(from string, to string) {
+	v := o.values[from]
+	o.delete(to)
+	o.sync()
+	keys := make([]string, len(o.keys))
+	copy(keys, o.keys)
+	if p, ok := o.index[from]; ok {
+		keys[p] = to
+		delete(o.index, from)
+		o.index[to] = p
+	}
+	delete(o.values, from)
+	o.values[to] = v
+	o.keys = keys
+}
+
+// MarshalJSON implements marshaler interface from encoding/json encode.go
+func (o *orderedObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.ordered() {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		val, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// objectOf returns the members of an object, ordered or not
+func objectOf(v interface{}) (map[string]interface{}, bool) {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return o, true
+	case *orderedObject:
+		return o.values, true
+	default:
+		return nil, false
+	}
+}
+
+// objectKeys returns the keys of an object in insertion order if ordered, else in sorted order
+// The returned keys must not be modified
+func objectKeys(v interface{}) []string {
+	switch o := v.(type) {
+	case map[string]interface{}:
+		return sortedKeys(o)
+	case *orderedObject:
+		return o.ordered()
+	default:
+		return nil
+	}
+}
+
+// setMember sets a member of an object
+func setMember(obj interface{}, k string, v interface{}) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		o[k] = v
+	case *orderedObject:
+		o.set(k, v)
+	}
+}
+
+// deleteMember deletes a member of an object
+func deleteMember(obj interface{}, k string) {
+	switch o := obj.(type) {
+	case map[string]interface{}:
+		delete(o, k)
+	case *orderedObject:
+		o.delete(k)
+	}
+}
+
+// newObjectLike creates an empty object, ordered if v is or contains ordered objects
+func newObjectLike(v interface{}, size int) interface{} {
+	if isOrdered(v) {
+		return newOrderedObject(size)
+	}
+	return make(map[string]interface{}, size)
+}
+
+// isOrdered checks if v is an ordered object or an array of ordered objects
+func isOrdered(v interface{}) bool {
+	switch cv := v.(type) {
+	case *orderedObject:
+		return true
+	case []interface{}:
+		for _, item := range cv {
+			if _, ok := item.(*orderedObject); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// decodeOrdered decodes the next value of dec with ordered objects
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t {
+	case json.Delim('{'):
+		o := newOrderedObject(0)
+		for dec.More() {
+			k, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			o.set(k.(string), v)
+		}
+		_, err := dec.Token()
+		return o, err
+
+	case json.Delim('['):
+		a := []interface{}{}
+		for dec.More() {
+			v, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		_, err := dec.Token()
+		return a, err
+
+	default:
+		return t, nil
+	}
+}
+
+// plainValue deeply copies v, ordered objects are converted to maps
+// Values nested deeper than maxBindDepth (ie cycles) are kept as is
+func plainValue(v interface{}, depth int) interface{} {
+	if depth > maxBindDepth {
+		return v
+	}
+	switch cv := v.(type) {
+	case map[string]interface{}, *orderedObject:
+		o, _ := objectOf(cv)
+		m := make(map[string]interface{}, len(o))
+		for k, item := range o {
+			m[k] = plainValue(item, depth+1)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(cv))
+		for i, item := range cv {
+			a[i] = plainValue(item, depth+1)
+		}
+		return a
+	}
+	return v
+}
@@ -0,0 +1,195 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/datasweet/jsonmap/tabify"
+	"github.com/stretchr/testify/assert"
+)
+
+const jsonOrdered = `{"query":{"bool":{"must":[{"term":{"z":1}},{"range":{"date":{"gte":"now-1d","lt":"now"}}}]}},"size":0,"aggs":{"terms":{"terms":{"field":"host"}}}}`
+
+func TestOrdered(t *testing.T) {
+	j, err := jsonmap.ParseString(jsonOrdered, jsonmap.Ordered(true))
+	assert.NoError(t, err)
+	assert.True(t, j.IsOrdered())
+	assert.True(t, j.IsObject())
+	assert.Equal(t, jsonmap.ObjectKind, j.Kind())
+	assert.Equal(t, jsonOrdered, j.Stringify())
+	assert.Equal(t, []string{"query", "size", "aggs"}, j.Keys())
+	assert.Equal(t, []string{"gte", "lt"}, j.Get("query.bool.must[1].range.date").Keys())
+	assert.Equal(t, float64(0), j.Get("size").AsFloat())
+	assert.Len(t, j.AsObject(), 3)
+
+	var paths []string
+	j.ForEach(func(k string, v *jsonmap.Json) bool {
+		paths = append(paths, v.Path())
+		return true
+	})
+	assert.Equal(t, []string{"query", "size", "aggs"}, paths)
+
+	// without the option
+	assert.False(t, jsonmap.FromString(jsonOrdered).IsOrdered())
+	assert.ElementsMatch(t, []string{"aggs", "query", "size"}, jsonmap.FromString(jsonOrdered).Keys())
+
+	// with numbers
+	j, err = jsonmap.ParseString(`{"b":12345678901234567890,"a":[1.50]}`, jsonmap.Ordered(true), jsonmap.UseNumber(true))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"b":12345678901234567890,"a":[1.50]}`, j.Stringify())
+
+	// errors
+	_, err = jsonmap.ParseString(`{"b":1,"a":}`, jsonmap.Ordered(true))
+	assert.EqualError(t, err, "jsonmap: invalid character '}' looking for beginning of value at line 1, column 12 (offset 11) near `{\"b\":1,\"a\":}`")
+}
+
+func TestOrderedMutations(t *testing.T) {
+	j, _ := jsonmap.ParseString(`{"size":0,"query":{"match_all":{}}}`, jsonmap.Ordered(true))
+
+	assert.True(t, j.Set("aggs.hosts.terms.field", "host"))
+	assert.True(t, j.Set("aggs.hosts.terms.size", 10))
+	assert.True(t, j.Set("size", 10))
+	assert.True(t, j.Set("aggs.hosts.aggs.max.max.field", "bytes"))
+	assert.Equal(t, `{"size":10,"query":{"match_all":{}},"aggs":{"hosts":{"terms":{"field":"host","size":10},"aggs":{"max":{"max":{"field":"bytes"}}}}}}`, j.Stringify())
+	assert.True(t, j.Get("aggs.hosts").IsOrdered())
+
+	assert.True(t, j.Unset("query"))
+	assert.True(t, j.Set("query", "again"))
+	assert.Equal(t, []string{"size", "aggs", "query"}, j.Keys())
+
+	// rename in place
+	assert.True(t, j.Rewrite("aggs.hosts.terms.field", "aggs.hosts.terms.script"))
+	assert.Equal(t, []string{"script", "size"}, j.Get("aggs.hosts.terms").Keys())
+	assert.True(t, j.Rewrite("size", "query"))
+	assert.Equal(t, []string{"query", "aggs"}, j.Keys())
+	assert.Equal(t, int64(10), j.Get("query").AsInt())
+
+	// moved to another object
+	assert.True(t, j.Rewrite("aggs.hosts.terms.size", "aggs.hosts.size"))
+	assert.Equal(t, []string{"terms", "aggs", "size"}, j.Get("aggs.hosts").Keys())
+
+	// map accessed directly
+	j.AsObject()["direct"] = true
+	assert.Equal(t, []string{"query", "aggs", "direct"}, j.Keys())
+	delete(j.AsObject(), "aggs")
+	assert.Equal(t, `{"query":10,"direct":true}`, j.Stringify())
+
+	// unset while iterating
+	j.ForEach(func(k string, v *jsonmap.Json) bool {
+		j.Unset(k)
+		return true
+	})
+	assert.Equal(t, "{}", j.Stringify())
+}
+
+func TestOrderedData(t *testing.T) {
+	j, _ := jsonmap.ParseString(`{"b":{"d":1,"c":[{"e":2}]},"a":2}`, jsonmap.Ordered(true))
+	o, ok := j.Data().(map[string]interface{})
+	if assert.True(t, ok) {
+		assert.Equal(t, map[string]interface{}{"d": 1.0, "c": []interface{}{map[string]interface{}{"e": 2.0}}}, o["b"])
+		assert.Equal(t, `{"a":2,"b":{"c":[{"e":2}],"d":1}}`, jsonmap.FromMap(o).Stringify())
+	}
+	_, ok = j.Get("b.c").Data().([]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, j.Get("b.d").Data())
+
+	// copied
+	o["a"] = 3
+	assert.Equal(t, int64(2), j.Get("a").AsInt())
+
+	// the order is kept when moved
+	assert.True(t, j.Rewrite("b", "g.f"))
+	assert.Equal(t, `{"a":2,"g":{"f":{"d":1,"c":[{"e":2}]}}}`, j.Stringify())
+}
+
+func TestOrderedConcurrentReads(t *testing.T) {
+	j, _ := jsonmap.ParseString(`{"b":1,"a":2}`, jsonmap.Ordered(true))
+	o := j.AsObject()
+	o["d"] = 3
+	delete(o, "b")
+	o["c"] = 4
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, []string{"a", "c", "d"}, j.Keys())
+			assert.Equal(t, `{"a":2,"c":4,"d":3}`, j.Stringify())
+		}()
+	}
+	wg.Wait()
+
+	// the order is repaired by the next modification
+	assert.True(t, j.Set("b", 5))
+	assert.Equal(t, []string{"a", "c", "d", "b"}, j.Keys())
+}
+
+func TestOrderedPropagation(t *testing.T) {
+	a, _ := jsonmap.ParseString(`{"z":1,"y":{"b":1,"a":2}}`, jsonmap.Ordered(true))
+	b := jsonmap.FromString(`{"x":3,"z":4}`)
+
+	assert.Equal(t, `{"z":4,"y":{"b":1,"a":2},"x":3}`, jsonmap.Merge(a, b).Stringify())
+	assert.Equal(t, `{"z":1,"y":{"b":1,"a":2}}`, a.Clone().Stringify())
+	assert.True(t, a.Clone().IsOrdered())
+
+	deep, err := jsonmap.DeepMerge(nil, a, jsonmap.FromString(`{"y":{"d":1,"c":2}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"z":1,"y":{"b":1,"a":2,"c":2,"d":1}}`, deep.Stringify())
+
+	patched := jsonmap.MergePatch(a, jsonmap.FromString(`{"z":null,"w":{"c":1}}`))
+	assert.Equal(t, `{"y":{"b":1,"a":2},"w":{"c":1}}`, patched.Stringify())
+
+	assert.NoError(t, a.ApplyPatch(jsonmap.FromString(`[{"op":"add","path":"/y/0","value":0}]`)))
+	assert.Equal(t, `{"z":1,"y":{"b":1,"a":2,"0":0}}`, a.Stringify())
+
+	// ordered and unordered objects are equal
+	assert.True(t, jsonmap.Equal(a, jsonmap.FromString(`{"y":{"0":0,"a":2,"b":1},"z":1}`)))
+	assert.Empty(t, jsonmap.Diff(a, jsonmap.FromString(`{"y":{"0":0,"a":2,"b":1},"z":1}`)))
+
+	nodes, err := a.Query("$.y.*")
+	assert.NoError(t, err)
+	assert.Len(t, nodes, 3)
+	assert.Equal(t, "y.b", nodes[0].Path())
+
+	// encoding/json
+	var s struct {
+		J *jsonmap.Json `json:"j"`
+	}
+	s.J = a
+	bytes, err := json.Marshal(s)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"j":{"z":1,"y":{"b":1,"a":2,"0":0}}}`, string(bytes))
+
+	o := jsonmap.NewOrdered()
+	o.Set("b", 1)
+	o.Set("a", 2)
+	assert.Equal(t, `{"b":1,"a":2}`, o.Stringify())
+}
+
+func TestOrderedTabify(t *testing.T) {
+	j, _ := jsonmap.ParseString(`{"rows":[{"name":"a","count":1,"avg":2},{"name":"b","count":3,"avg":4}]}`, jsonmap.Ordered(true))
+
+	table, err := tabify.Slice(j)
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"rows#name", "rows#count", "rows#avg"}, table[0])
+
+	rows, err := tabify.JSON(j)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"rows#name":"a","rows#count":1,"rows#avg":2}`, rows[0].Stringify())
+}
+
+func BenchmarkOrderedLargeObject(b *testing.B) {
+	o := make(map[string]int, 20000)
+	for i := 0; i < 20000; i++ {
+		o[fmt.Sprintf("key%d", i)] = i
+	}
+	data, _ := json.Marshal(o)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonmap.Parse(data, jsonmap.Ordered(true))
+	}
+}
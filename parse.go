@@ -0,0 +1,207 @@
+package jsonmap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ParseOptions are our parse options
+type ParseOptions struct {
+	UseNumber bool
+	Ordered   bool
+}
+
+// ParseOption is a parse option setter
+type ParseOption func(o *ParseOptions)
+
+// defaultParseOptions are used by FromBytes, FromString, UnmarshalJSON
+// and as the base of the options given to Parse
+var defaultParseOptions ParseOptions
+
+// SetDefaultParseOptions sets the package-level parse options.
+// It should be called once, at program initialization
+func SetDefaultParseOptions(opt ...ParseOption) {
+	defaultParseOptions = newParseOptions(opt...)
+}
+
+// DefaultParseOptions returns the package-level parse options
+func DefaultParseOptions() ParseOptions {
+	return defaultParseOptions
+}
+
+func newParseOptions(opt ...ParseOption) ParseOptions {
+	opts := ParseOptions{}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return opts
+}
+
+// UseNumber decodes numbers as json.Number instead of float64
+// Numbers are kept as written in the input, so big integers are not corrupted
+// default : false
+func UseNumber(v bool) ParseOption {
+	return func(opts *ParseOptions) {
+		opts.UseNumber = v
+	}
+}
+
+// snippetRadius is the number of bytes kept on each side of
+// a syntax error in SyntaxError.Snippet
+const snippetRadius = 20
+
+// SyntaxError describes a malformed json input
+type SyntaxError struct {
+	msg     string
+	Offset  int64  // byte offset of the error in the input
+	Line    int    // line of the error, starting at 1
+	Column  int    // column of the error, starting at 1
+	Snippet string // surrounding input
+	err     error
+}
+
+// Error implements the error interface
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("jsonmap: %s at line %d, column %d (offset %d) near `%s`", e.msg, e.Line, e.Column, e.Offset, e.Snippet)
+}
+
+// Unwrap returns the underlying encoding/json error
+func (e *SyntaxError) Unwrap() error {
+	return e.err
+}
+
+// newSyntaxError builds a SyntaxError at offset in data
+func newSyntaxError(data []byte, offset int64, msg string, err error) *SyntaxError {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	line, column := 1, 1
+	for _, b := range data[:offset] {
+		if b == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	start, end := offset-snippetRadius, offset+snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	if end > int64(len(data)) {
+		end = int64(len(data))
+	}
+	snippet := strings.NewReplacer("\n", "\\n", "\r", "\\r", "\t", "\\t").Replace(string(data[start:end]))
+
+	return &SyntaxError{
+		msg:     msg,
+		Offset:  offset,
+		Line:    line,
+		Column:  column,
+		Snippet: snippet,
+		err:     err,
+	}
+}
+
+// wrapError converts an encoding/json decoding error into a SyntaxError
+func wrapError(data []byte, err error) error {
+	var se *json.SyntaxError
+	if errors.As(err, &se) {
+		// encoding/json reports the offset after the invalid byte
+		offset := se.Offset
+		if offset < int64(len(data)) || !strings.HasPrefix(se.Error(), "unexpected end") {
+			offset--
+		}
+		return newSyntaxError(data, offset, se.Error(), err)
+	}
+	return err
+}
+
+// decode unmarshals data according to opts
+func decode(data []byte, opts ParseOptions) (interface{}, error) {
+	var v interface{}
+
+	if opts.Ordered {
+		// data is validated first, invalid data is decoded to get the same errors
+		if !json.Valid(data) {
+			if _, err := decode(data, ParseOptions{UseNumber: true}); err != nil {
+				return nil, err
+			}
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if opts.UseNumber {
+			dec.UseNumber()
+		}
+		return decodeOrdered(dec)
+	}
+
+	if !opts.UseNumber {
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, wrapError(data, err)
+		}
+		return v, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, newSyntaxError(data, int64(len(data)), "unexpected end of JSON input", err)
+		}
+		return nil, wrapError(data, err)
+	}
+
+	// Only whitespaces are allowed after the top-level value
+	for offset := dec.InputOffset(); offset < int64(len(data)); offset++ {
+		switch c := data[offset]; c {
+		case ' ', '\t', '\r', '\n':
+		default:
+			msg := fmt.Sprintf("invalid character %q after top-level value", c)
+			return nil, newSyntaxError(data, offset, msg, nil)
+		}
+	}
+	return v, nil
+}
+
+// Parse creates a Json from bytes
+// Options are applied on top of the package-level ones, see SetDefaultParseOptions
+// Returns a *SyntaxError if bytes are not a valid json
+func Parse(data []byte, opt ...ParseOption) (*Json, error) {
+	opts := defaultParseOptions
+	for _, o := range opt {
+		o(&opts)
+	}
+	v, err := decode(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Json{data: v}, nil
+}
+
+// ParseString creates a Json from a string
+// Returns a *SyntaxError if the string is not a valid json
+func ParseString(str string, opt ...ParseOption) (*Json, error) {
+	return Parse([]byte(str), opt...)
+}
+
+// ParseReader creates a Json from the whole content of a reader
+// Returns a *SyntaxError if the content is not a valid json
+func ParseReader(r io.Reader, opt ...ParseOption) (*Json, error) {
+	if r == nil {
+		return nil, errors.New("jsonmap: nil reader")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, opt...)
+}
@@ -0,0 +1,155 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	j, err := jsonmap.Parse([]byte(jsonTest))
+	assert.NoError(t, err)
+	assert.True(t, j.IsObject())
+	assert.Equal(t, "hello", j.Get("string").AsString())
+
+	j, err = jsonmap.ParseString("null")
+	assert.NoError(t, err)
+	assert.True(t, j.IsNil())
+}
+
+func TestParseSyntaxError(t *testing.T) {
+	t.Run("invalid character", func(t *testing.T) {
+		j, err := jsonmap.ParseString("{\n  \"a\": x\n}")
+		assert.Nil(t, j)
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(9), se.Offset)
+		assert.Equal(t, 2, se.Line)
+		assert.Equal(t, 8, se.Column)
+		assert.Equal(t, "{\\n  \"a\": x\\n}", se.Snippet)
+		assert.Contains(t, err.Error(), "line 2, column 8")
+
+		var jse *json.SyntaxError
+		assert.True(t, errors.As(err, &jse))
+	})
+
+	t.Run("truncated input", func(t *testing.T) {
+		_, err := jsonmap.ParseString(`{ "hits": { "total": 12`)
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(23), se.Offset)
+		assert.Equal(t, 1, se.Line)
+		assert.Equal(t, 24, se.Column)
+		assert.Contains(t, err.Error(), "unexpected end of JSON input")
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		_, err := jsonmap.ParseString("")
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(0), se.Offset)
+	})
+
+	t.Run("trailing data", func(t *testing.T) {
+		_, err := jsonmap.ParseString(`{ "a": 1 } 2`)
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(11), se.Offset)
+	})
+
+	t.Run("long snippet is truncated", func(t *testing.T) {
+		input := "[" + strings.Repeat("1,", 50) + "x]"
+		_, err := jsonmap.ParseString(input)
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(101), se.Offset)
+		assert.Equal(t, strings.Repeat("1,", 10)+"x]", se.Snippet)
+	})
+}
+
+func TestParseReader(t *testing.T) {
+	j, err := jsonmap.ParseReader(strings.NewReader(`{ "a": [1, 2] }`))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), j.Get("a[1]").AsInt())
+
+	_, err = jsonmap.ParseReader(strings.NewReader(`{ "a": [1, 2 }`))
+	assert.Error(t, err)
+
+	_, err = jsonmap.ParseReader(nil)
+	assert.Error(t, err)
+}
+
+func TestParseUseNumber(t *testing.T) {
+	const input = `{"id":9007199254740993,"items":[18446744073709551615,-1,1.50],"ratio":0.1000000000000000055511151231257827}`
+
+	t.Run("default decodes to float64", func(t *testing.T) {
+		j, err := jsonmap.ParseString(input)
+		assert.NoError(t, err)
+		assert.IsType(t, float64(0), j.Get("id").Data())
+		assert.NotEqual(t, int64(9007199254740993), j.Get("id").AsInt())
+	})
+
+	t.Run("keeps numbers as written", func(t *testing.T) {
+		j, err := jsonmap.ParseString(input, jsonmap.UseNumber(true))
+		assert.NoError(t, err)
+		assert.Equal(t, json.Number("9007199254740993"), j.Get("id").Data())
+		assert.Equal(t, int64(9007199254740993), j.Get("id").AsInt())
+		assert.Equal(t, uint64(18446744073709551615), j.Get("items[0]").AsUint())
+		assert.Equal(t, int64(-1), j.Get("items[1]").AsInt())
+		assert.Equal(t, 0.1, j.Get("ratio").AsFloat())
+		assert.Equal(t, input, j.Stringify())
+	})
+
+	t.Run("survives set, clone and merge", func(t *testing.T) {
+		j, err := jsonmap.ParseString(input, jsonmap.UseNumber(true))
+		assert.NoError(t, err)
+
+		other := jsonmap.New()
+		assert.True(t, other.Set("copy", j.Get("id")))
+		assert.Equal(t, `{"copy":9007199254740993}`, other.Stringify())
+
+		clone := j.Clone()
+		assert.Equal(t, input, clone.Stringify())
+		clone.Set("id", 1)
+		assert.Equal(t, json.Number("9007199254740993"), j.Get("id").Data())
+
+		merge := jsonmap.Merge(j, other)
+		assert.Equal(t, json.Number("9007199254740993"), merge.Get("copy").Data())
+		assert.Equal(t, json.Number("18446744073709551615"), merge.Get("items[0]").Data())
+	})
+
+	t.Run("syntax errors", func(t *testing.T) {
+		_, err := jsonmap.ParseString(`{ "a": 1 } 2`, jsonmap.UseNumber(true))
+		var se *jsonmap.SyntaxError
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(11), se.Offset)
+
+		_, err = jsonmap.ParseString(`{ "a": 1`, jsonmap.UseNumber(true))
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(8), se.Offset)
+
+		_, err = jsonmap.ParseString(`{ "a": x }`, jsonmap.UseNumber(true))
+		assert.True(t, errors.As(err, &se))
+		assert.Equal(t, int64(7), se.Offset)
+	})
+
+	t.Run("package-level default", func(t *testing.T) {
+		jsonmap.SetDefaultParseOptions(jsonmap.UseNumber(true))
+		defer jsonmap.SetDefaultParseOptions()
+		assert.True(t, jsonmap.DefaultParseOptions().UseNumber)
+
+		assert.Equal(t, json.Number("9007199254740993"), jsonmap.FromString(input).Get("id").Data())
+
+		var dummy Dummy
+		assert.NoError(t, json.Unmarshal([]byte(`{"raw":`+input+`}`), &dummy))
+		assert.Equal(t, input, dummy.Raw.Stringify())
+
+		j, err := jsonmap.ParseString(input, jsonmap.UseNumber(false))
+		assert.NoError(t, err)
+		assert.IsType(t, float64(0), j.Get("id").Data())
+	})
+}
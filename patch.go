@@ -0,0 +1,401 @@
+package jsonmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PatchError is returned when a JSON Patch (RFC 6902) operation can't be applied
+type PatchError struct {
+	Index int    // index of the operation in the patch, -1 if the patch itself is invalid
+	Op    string // operation, ie "add"
+	Path  string // path of the operation
+	msg   string
+	err   error
+}
+
+// Error implements the error interface
+func (e *PatchError) Error() string {
+	if e.Index < 0 {
+		return fmt.Sprintf("jsonmap: patch: %s", e.msg)
+	}
+	return fmt.Sprintf("jsonmap: patch operation %d (%s '%s'): %s", e.Index, e.Op, e.Path, e.msg)
+}
+
+// Unwrap returns the underlying *PointerError if any
+func (e *PatchError) Unwrap() error {
+	return e.err
+}
+
+// ApplyPatch applies a JSON Patch (RFC 6902) to the current json.
+// Supports add, remove, replace, move, copy and test operations.
+// The patch is applied atomically : on error, the json is left unchanged
+// Example : ApplyPatch(FromString(`[{ "op": "replace", "path": "/settings/replicas", "value": 2 }]`))
+func (j *Json) ApplyPatch(patch *Json) error {
+	j.modify()
+	ops := patch.AsArray()
+	if ops == nil {
+		return &PatchError{Index: -1, msg: "must be an array of operations"}
+	}
+
+	data := cloneValue(j.data)
+	for i, item := range ops {
+		op := &Json{data: item}
+		var err error
+		if data, err = applyOperation(data, op); err != nil {
+			if pe, ok := err.(*PatchError); ok {
+				pe.Index = i
+				pe.Op = op.Get("op").AsString()
+				pe.Path = op.Get("path").AsString()
+			}
+			return err
+		}
+	}
+	j.data = data
+	return nil
+}
+
+// applyOperation applies a single patch operation to data
+func applyOperation(data interface{}, op *Json) (interface{}, error) {
+	if !op.IsObject() {
+		return nil, &PatchError{msg: "operation must be an object"}
+	}
+	if _, ok := op.Get("op").asString(); !ok {
+		return nil, &PatchError{msg: "missing 'op'"}
+	}
+	path, ok := op.Get("path").asString()
+	if !ok {
+		return nil, &PatchError{msg: "missing 'path'"}
+	}
+	keys, err := parsePointer(path)
+	if err != nil {
+		return nil, &PatchError{msg: "invalid 'path'", err: err}
+	}
+
+	// value of add, replace and test
+	value, hasValue := op.AsObject()["value"]
+
+	// from of move and copy
+	var from []pathKey
+	var fromPath string
+	switch op.Get("op").AsString() {
+	case "move", "copy":
+		if fromPath, ok = op.Get("from").asString(); !ok {
+			return nil, &PatchError{msg: "missing 'from'"}
+		}
+		if from, err = parsePointer(fromPath); err != nil {
+			return nil, &PatchError{msg: "invalid 'from'", err: err}
+		}
+	case "add", "replace", "test":
+		if !hasValue {
+			return nil, &PatchError{msg: "missing 'value'"}
+		}
+	}
+
+	switch op.Get("op").AsString() {
+	case "add":
+		return patchAdd(data, keys, cloneValue(value))
+
+	case "remove":
+		return patchRemove(data, keys)
+
+	case "replace":
+		if _, ok := getValue(data, keys); !ok {
+			return nil, &PatchError{msg: "value not found"}
+		}
+		data, _ = setValue(data, keys, cloneValue(value))
+		return data, nil
+
+	case "move":
+		if fromPath == path {
+			if _, ok := getValue(data, from); !ok {
+				return nil, &PatchError{msg: "'from' value not found"}
+			}
+			return data, nil
+		}
+		if strings.HasPrefix(path, fromPath+"/") {
+			return nil, &PatchError{msg: "can't move a value into one of its children"}
+		}
+		v, ok := getValue(data, from)
+		if !ok {
+			return nil, &PatchError{msg: "'from' value not found"}
+		}
+		if data, err = patchRemove(data, from); err != nil {
+			return nil, err
+		}
+		return patchAdd(data, keys, v)
+
+	case "copy":
+		v, ok := getValue(data, from)
+		if !ok {
+			return nil, &PatchError{msg: "'from' value not found"}
+		}
+		return patchAdd(data, keys, cloneValue(v))
+
+	case "test":
+		v, ok := getValue(data, keys)
+		if !ok {
+			return nil, &PatchError{msg: "value not found"}
+		}
+		if !valuesEqual(v, value) {
+			return nil, &PatchError{msg: "test failed"}
+		}
+		return data, nil
+
+	default:
+		return nil, &PatchError{msg: "unknown operation"}
+	}
+}
+
+// patchAdd adds a value as defined by RFC 6902 : the parent must exist,
+// array items are inserted and the "-" token appends the value
+func patchAdd(data interface{}, keys []pathKey, value interface{}) (interface{}, error) {
+	if len(keys) == 0 {
+		return value, nil
+	}
+	parentKeys, k := keys[:len(keys)-1], keys[len(keys)-1]
+	parent, ok := getValue(data, parentKeys)
+	if !ok {
+		return nil, &PatchError{msg: "parent not found"}
+	}
+
+	if _, ok := objectOf(parent); ok {
+		setMember(parent, k.name, value)
+		return data, nil
+	}
+
+	switch p := parent.(type) {
+	case []interface{}:
+		idx := len(p)
+		if !k.append {
+			if !k.isIndex || k.index > len(p) {
+				return nil, &PatchError{msg: "invalid array index"}
+			}
+			idx = k.index
+		}
+		items := make([]interface{}, 0, len(p)+1)
+		items = append(items, p[:idx]...)
+		items = append(items, value)
+		items = append(items, p[idx:]...)
+		data, _ = setValue(data, parentKeys, items)
+		return data, nil
+
+	default:
+		return nil, &PatchError{msg: "parent is not an object or an array"}
+	}
+}
+
+// patchRemove removes an existing value
+func patchRemove(data interface{}, keys []pathKey) (interface{}, error) {
+	if len(keys) == 0 {
+		return nil, &PatchError{msg: "can't remove the root"}
+	}
+	if _, ok := getValue(data, keys); !ok {
+		return nil, &PatchError{msg: "value not found"}
+	}
+	data, _ = unsetValue(data, keys)
+	return data, nil
+}
+
+// CreatePatch generates a minimal JSON Patch (RFC 6902) transforming from into to
+// Objects are compared key by key, and arrays by their longest common subsequence of items,
+// so inserting or removing an item is a single add or remove operation.
+// Very large arrays which differ in their middle are compared index by index
+func CreatePatch(from *Json, to *Json) *Json {
+	ops := []interface{}{}
+	patchValues(dataOf(from), dataOf(to), "", &ops)
+	return &Json{data: ops}
+}
+
+// patchValues appends the operations transforming a into b
+func patchValues(a interface{}, b interface{}, pointer string, ops *[]interface{}) {
+	if va, ok := objectOf(a); ok {
+		if vb, ok := objectOf(b); ok {
+			for _, k := range objectKeys(a) {
+				p := pointer + "/" + EscapePointer(k)
+				if w, ok := vb[k]; ok {
+					patchValues(va[k], w, p, ops)
+				} else {
+					*ops = append(*ops, patchOperation("remove", p, nil))
+				}
+			}
+			for _, k := range objectKeys(b) {
+				if _, ok := va[k]; !ok {
+					*ops = append(*ops, patchOperation("add", pointer+"/"+EscapePointer(k), vb[k]))
+				}
+			}
+			return
+		}
+	}
+
+	if va, ok := a.([]interface{}); ok {
+		if vb, ok := b.([]interface{}); ok {
+			patchArrays(va, vb, pointer, ops)
+			return
+		}
+	}
+
+	if !valuesEqual(a, b) {
+		*ops = append(*ops, patchOperation("replace", pointer, b))
+	}
+}
+
+// maxLCSCells limits the size of the table of the longest common subsequence of two arrays,
+// larger arrays are compared index by index
+const maxLCSCells = 1 << 20
+
+// patchArrays appends the operations transforming the array a into b
+// The items of the longest common subsequence are kept, so inserting or removing an item is a single operation.
+// Other items are patched in place, removed or added, in order so each index is valid when applied
+func patchArrays(a []interface{}, b []interface{}, pointer string, ops *[]interface{}) {
+	pos := 0
+	gap := func(ga, gb []interface{}) {
+		for k := 0; k < len(ga) && k < len(gb); k++ {
+			patchValues(ga[k], gb[k], fmt.Sprintf("%s/%d", pointer, pos), ops)
+			pos++
+		}
+		// removes from the end to keep indexes valid
+		for k := len(ga) - len(gb) - 1; k >= 0; k-- {
+			*ops = append(*ops, patchOperation("remove", fmt.Sprintf("%s/%d", pointer, pos+k), nil))
+		}
+		for k := len(ga); k < len(gb); k++ {
+			*ops = append(*ops, patchOperation("add", fmt.Sprintf("%s/%d", pointer, pos), gb[k]))
+			pos++
+		}
+	}
+
+	i, j := 0, 0
+	for _, m := range commonItems(a, b) {
+		gap(a[i:m[0]], b[j:m[1]])
+		i, j = m[0]+1, m[1]+1
+		pos++
+	}
+	gap(a[i:], b[j:])
+}
+
+// commonItems returns the indexes in a and in b of the items of their longest common subsequence
+// The common prefix and suffix are matched first, the rest only if its table has at most maxLCSCells cells
+func commonItems(a []interface{}, b []interface{}) [][2]int {
+	var res [][2]int
+	start := 0
+	for start < len(a) && start < len(b) && valuesEqual(a[start], b[start]) {
+		res = append(res, [2]int{start, start})
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && valuesEqual(a[len(a)-1-end], b[len(b)-1-end]) {
+		end++
+	}
+
+	ma, mb := a[start:len(a)-end], b[start:len(b)-end]
+	if len(ma) > 0 && len(mb) > 0 && (len(ma)+1)*(len(mb)+1) <= maxLCSCells {
+		// lengths of the longest common subsequences of ma[i:] and mb[j:]
+		width := len(mb) + 1
+		lengths := make([]int, (len(ma)+1)*width)
+		for i := len(ma) - 1; i >= 0; i-- {
+			for j := len(mb) - 1; j >= 0; j-- {
+				switch {
+				case valuesEqual(ma[i], mb[j]):
+					lengths[i*width+j] = lengths[(i+1)*width+j+1] + 1
+				case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+					lengths[i*width+j] = lengths[(i+1)*width+j]
+				default:
+					lengths[i*width+j] = lengths[i*width+j+1]
+				}
+			}
+		}
+		for i, j := 0, 0; i < len(ma) && j < len(mb); {
+			switch {
+			case lengths[i*width+j] == lengths[(i+1)*width+j+1]+1 && valuesEqual(ma[i], mb[j]):
+				res = append(res, [2]int{start + i, start + j})
+				i++
+				j++
+			case lengths[(i+1)*width+j] >= lengths[i*width+j+1]:
+				i++
+			default:
+				j++
+			}
+		}
+	}
+
+	for k := end; k > 0; k-- {
+		res = append(res, [2]int{len(a) - k, len(b) - k})
+	}
+	return res
+}
+
+// patchOperation builds a patch operation, without value for remove
+func patchOperation(op string, pointer string, value interface{}) map[string]interface{} {
+	o := map[string]interface{}{
+		"op":   op,
+		"path": pointer,
+	}
+	if op != "remove" {
+		o["value"] = cloneValue(value)
+	}
+	return o
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to target and returns the result.
+// Null values delete keys, objects are merged recursively and other values are replaced.
+// target is not modified
+func MergePatch(target *Json, patch *Json) *Json {
+	return &Json{data: mergePatch(cloneValue(dataOf(target)), dataOf(patch))}
+}
+
+// mergePatch applies patch to target which can be modified
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	p, ok := objectOf(patch)
+	if !ok {
+		return cloneValue(patch)
+	}
+	t, ok := objectOf(target)
+	if !ok {
+		target = newObjectLike(patch, len(p))
+		t, _ = objectOf(target)
+	}
+	for _, k := range objectKeys(patch) {
+		if p[k] == nil {
+			deleteMember(target, k)
+			continue
+		}
+		setMember(target, k, mergePatch(t[k], p[k]))
+	}
+	return target
+}
+
+// CreateMergePatch generates a JSON Merge Patch (RFC 7396) transforming original into modified
+// As null deletes keys, null values of modified can't be set by the patch
+func CreateMergePatch(original *Json, modified *Json) *Json {
+	return &Json{data: createMergePatch(dataOf(original), dataOf(modified))}
+}
+
+func createMergePatch(original interface{}, modified interface{}) interface{} {
+	o, ok := objectOf(original)
+	if !ok {
+		return cloneValue(modified)
+	}
+	m, ok := objectOf(modified)
+	if !ok {
+		return cloneValue(modified)
+	}
+
+	patch := newObjectLike(modified, 0)
+	for _, k := range objectKeys(original) {
+		if _, ok := m[k]; !ok {
+			setMember(patch, k, nil)
+		}
+	}
+	for _, k := range objectKeys(modified) {
+		ov, ok := o[k]
+		if ok && valuesEqual(ov, m[k]) {
+			continue
+		}
+		if _, isObject := objectOf(m[k]); ok && isObject {
+			setMember(patch, k, createMergePatch(ov, m[k]))
+			continue
+		}
+		setMember(patch, k, cloneValue(m[k]))
+	}
+	return patch
+}
@@ -0,0 +1,223 @@
+package jsonmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyPatch(t *testing.T) {
+	// RFC 6902 appendix A
+	tests := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		{`{ "foo": "bar" }`, `[{ "op": "add", "path": "/baz", "value": "qux" }]`, `{ "baz": "qux", "foo": "bar" }`},
+		{`{ "foo": [ "bar", "baz" ] }`, `[{ "op": "add", "path": "/foo/1", "value": "qux" }]`, `{ "foo": [ "bar", "qux", "baz" ] }`},
+		{`{ "baz": "qux", "foo": "bar" }`, `[{ "op": "remove", "path": "/baz" }]`, `{ "foo": "bar" }`},
+		{`{ "foo": [ "bar", "qux", "baz" ] }`, `[{ "op": "remove", "path": "/foo/1" }]`, `{ "foo": [ "bar", "baz" ] }`},
+		{`{ "baz": "qux", "foo": "bar" }`, `[{ "op": "replace", "path": "/baz", "value": "boo" }]`, `{ "baz": "boo", "foo": "bar" }`},
+		{
+			`{ "foo": { "bar": "baz", "waldo": "fred" }, "qux": { "corge": "grault" } }`,
+			`[{ "op": "move", "from": "/foo/waldo", "path": "/qux/thud" }]`,
+			`{ "foo": { "bar": "baz" }, "qux": { "corge": "grault", "thud": "fred" } }`,
+		},
+		{`{ "foo": [ "all", "grass", "cows", "eat" ] }`, `[{ "op": "move", "from": "/foo/1", "path": "/foo/3" }]`, `{ "foo": [ "all", "cows", "eat", "grass" ] }`},
+		{`{ "foo": { "bar": "baz" } }`, `[{ "op": "add", "path": "/child", "value": { "grandchild": { } } }]`, `{ "foo": { "bar": "baz" }, "child": { "grandchild": { } } }`},
+		{`{ "foo": ["bar"] }`, `[{ "op": "add", "path": "/foo/-", "value": ["abc", "def"] }]`, `{ "foo": ["bar", ["abc", "def"]] }`},
+		{`{ "baz": "qux", "foo": [ "a", 2, "c" ] }`, `[{ "op": "test", "path": "/baz", "value": "qux" }, { "op": "test", "path": "/foo/1", "value": 2.0 }]`, `{ "baz": "qux", "foo": [ "a", 2, "c" ] }`},
+		{`{ "a/b": { "m~n": 1 } }`, `[{ "op": "copy", "from": "/a~1b/m~0n", "path": "/c" }, { "op": "move", "from": "/c", "path": "/c" }]`, `{ "a/b": { "m~n": 1 }, "c": 1 }`},
+		{`{ "a": 1 }`, `[{ "op": "replace", "path": "", "value": [1] }, { "op": "add", "path": "/1", "value": 2 }]`, `[1, 2]`},
+	}
+
+	for _, test := range tests {
+		j := jsonmap.FromString(test.doc)
+		assert.NoError(t, j.ApplyPatch(jsonmap.FromString(test.patch)), test.patch)
+		assert.JSONEq(t, test.expected, j.Stringify(), test.patch)
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		patch string
+		err   string
+	}{
+		{`{ "op": "add" }`, "jsonmap: patch: must be an array of operations"},
+		{`[{ "op": "add", "path": "/a" }]`, "jsonmap: patch operation 0 (add '/a'): missing 'value'"},
+		{`[{ "path": "/a" }]`, "jsonmap: patch operation 0 ( '/a'): missing 'op'"},
+		{`[{ "op": "add", "path": "/a/b/c", "value": 1 }]`, "jsonmap: patch operation 0 (add '/a/b/c'): parent not found"},
+		{`[{ "op": "add", "path": "/foo/5", "value": 1 }]`, "jsonmap: patch operation 0 (add '/foo/5'): invalid array index"},
+		{`[{ "op": "add", "path": "/baz/x", "value": 1 }]`, "jsonmap: patch operation 0 (add '/baz/x'): parent is not an object or an array"},
+		{`[{ "op": "remove", "path": "/a" }]`, "jsonmap: patch operation 0 (remove '/a'): value not found"},
+		{`[{ "op": "remove", "path": "" }]`, "jsonmap: patch operation 0 (remove ''): can't remove the root"},
+		{`[{ "op": "replace", "path": "/foo/2", "value": 1 }]`, "jsonmap: patch operation 0 (replace '/foo/2'): value not found"},
+		{`[{ "op": "move", "from": "/foo", "path": "/foo/0" }]`, "jsonmap: patch operation 0 (move '/foo/0'): can't move a value into one of its children"},
+		{`[{ "op": "copy", "from": "/a", "path": "/b" }]`, "jsonmap: patch operation 0 (copy '/b'): 'from' value not found"},
+		{`[{ "op": "copy", "path": "/b" }]`, "jsonmap: patch operation 0 (copy '/b'): missing 'from'"},
+		{`[{ "op": "test", "path": "/baz", "value": "bar" }]`, "jsonmap: patch operation 0 (test '/baz'): test failed"},
+		{`[{ "op": "add", "path": "/a", "value": 1 }, { "op": "invalid", "path": "/a" }]`, "jsonmap: patch operation 1 (invalid '/a'): unknown operation"},
+		{`[{ "op": "add", "path": "a", "value": 1 }]`, "jsonmap: patch operation 0 (add 'a'): invalid 'path'"},
+	}
+
+	for _, test := range tests {
+		j := jsonmap.FromString(`{ "baz": "qux", "foo": ["bar"] }`)
+		err := j.ApplyPatch(jsonmap.FromString(test.patch))
+		assert.EqualError(t, err, test.err, test.patch)
+		// atomic
+		assert.JSONEq(t, `{ "baz": "qux", "foo": ["bar"] }`, j.Stringify(), test.patch)
+	}
+
+	j := jsonmap.New()
+	err := j.ApplyPatch(jsonmap.FromString(`[{ "op": "add", "path": "/~2", "value": 1 }]`))
+	var pe *jsonmap.PatchError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, 0, pe.Index)
+	var ptr *jsonmap.PointerError
+	assert.True(t, errors.As(err, &ptr))
+}
+
+func TestCreatePatch(t *testing.T) {
+	from := jsonmap.FromString(`{
+		"title": "config",
+		"settings": { "replicas": 1, "shards": 1, "a/b": true },
+		"tags": ["a", "b", "c", "d"],
+		"hosts": ["x"]
+	}`)
+	to := jsonmap.FromString(`{
+		"title": "config",
+		"settings": { "replicas": 2, "shards": 1, "refresh": "1s" },
+		"tags": ["a", "B"],
+		"hosts": ["x", "y", { "name": "z" }],
+		"owner": null
+	}`)
+
+	patch := jsonmap.CreatePatch(from, to)
+	assert.JSONEq(t, `[
+		{ "op": "add", "path": "/hosts/1", "value": "y" },
+		{ "op": "add", "path": "/hosts/2", "value": { "name": "z" } },
+		{ "op": "remove", "path": "/settings/a~1b" },
+		{ "op": "replace", "path": "/settings/replicas", "value": 2 },
+		{ "op": "add", "path": "/settings/refresh", "value": "1s" },
+		{ "op": "replace", "path": "/tags/1", "value": "B" },
+		{ "op": "remove", "path": "/tags/3" },
+		{ "op": "remove", "path": "/tags/2" },
+		{ "op": "add", "path": "/owner", "value": null }
+	]`, patch.Stringify())
+
+	assert.NoError(t, from.ApplyPatch(patch))
+	assert.True(t, jsonmap.Equal(from, to))
+
+	assert.Equal(t, "[]", jsonmap.CreatePatch(to, to.Clone()).Stringify())
+	assert.JSONEq(t, `[{ "op": "replace", "path": "", "value": [1] }]`, jsonmap.CreatePatch(to, jsonmap.FromString(`[1]`)).Stringify())
+}
+
+func TestCreatePatchArrays(t *testing.T) {
+	for _, tc := range []struct{ from, to, patch string }{
+		{`[1, 2, 3, 4, 5]`, `[0, 1, 2, 3, 4, 5]`, `[{ "op": "add", "path": "/0", "value": 0 }]`},
+		{`[1, 2, 3, 4, 5]`, `[2, 3, 4, 5]`, `[{ "op": "remove", "path": "/0" }]`},
+		{`[1, 2, 3, 4, 5]`, `[1, 2, 9, 3, 4, 5]`, `[{ "op": "add", "path": "/2", "value": 9 }]`},
+		{`[1, 2, 3, 4, 5]`, `[1, 2, 4, 5]`, `[{ "op": "remove", "path": "/2" }]`},
+		{`[1, 2, 3, 4, 5]`, `[1, 9, 3, 8, 5]`, `[{ "op": "replace", "path": "/1", "value": 9 }, { "op": "replace", "path": "/3", "value": 8 }]`},
+		{`[1, 2, 3, 4, 5]`, `[0, 1, 3, 5, 6]`, `[
+			{ "op": "add", "path": "/0", "value": 0 },
+			{ "op": "remove", "path": "/2" },
+			{ "op": "remove", "path": "/3" },
+			{ "op": "add", "path": "/4", "value": 6 }
+		]`},
+		{`[{ "id": 1, "v": 1 }, { "id": 2 }]`, `[{ "id": 0 }, { "id": 1, "v": 2 }, { "id": 2 }]`, `[
+			{ "op": "replace", "path": "/0/id", "value": 0 },
+			{ "op": "remove", "path": "/0/v" },
+			{ "op": "add", "path": "/1", "value": { "id": 1, "v": 2 } }
+		]`},
+		{`[1, 2]`, `[]`, `[{ "op": "remove", "path": "/1" }, { "op": "remove", "path": "/0" }]`},
+	} {
+		from, to := jsonmap.FromString(tc.from), jsonmap.FromString(tc.to)
+		patch := jsonmap.CreatePatch(from, to)
+		assert.JSONEq(t, tc.patch, patch.Stringify(), tc.from+" => "+tc.to)
+		assert.NoError(t, from.ApplyPatch(patch))
+		assert.True(t, jsonmap.Equal(from, to), tc.from+" => "+tc.to)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	// RFC 7396 appendix A
+	tests := []struct {
+		target   string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, test := range tests {
+		target := jsonmap.FromString(test.target)
+		res := jsonmap.MergePatch(target, jsonmap.FromString(test.patch))
+		assert.JSONEq(t, test.expected, res.Stringify(), test.patch)
+		assert.JSONEq(t, test.target, target.Stringify(), test.patch)
+	}
+
+	// patch is not shared
+	patch := jsonmap.FromString(`{"a":{"b":[1]}}`)
+	res := jsonmap.MergePatch(jsonmap.New(), patch)
+	res.Set("a.b[0]", 2)
+	assert.Equal(t, int64(1), patch.Get("a.b[0]").AsInt())
+}
+
+func TestCreateMergePatch(t *testing.T) {
+	original := jsonmap.FromString(`{
+		"title": "Goodbye!",
+		"author": { "givenName": "John", "familyName": "Doe" },
+		"tags": ["example", "sample"],
+		"content": "This will be unchanged"
+	}`)
+	modified := jsonmap.FromString(`{
+		"title": "Hello!",
+		"author": { "givenName": "John" },
+		"tags": ["example"],
+		"content": "This will be unchanged",
+		"phoneNumber": "+01-123-456-7890"
+	}`)
+
+	patch := jsonmap.CreateMergePatch(original, modified)
+	assert.JSONEq(t, `{
+		"title": "Hello!",
+		"author": { "familyName": null },
+		"tags": ["example"],
+		"phoneNumber": "+01-123-456-7890"
+	}`, patch.Stringify())
+	assert.True(t, jsonmap.Equal(modified, jsonmap.MergePatch(original, patch)))
+
+	assert.Equal(t, "{}", jsonmap.CreateMergePatch(original, original.Clone()).Stringify())
+	assert.Equal(t, "[1]", jsonmap.CreateMergePatch(original, jsonmap.FromString(`[1]`)).Stringify())
+	assert.JSONEq(t, `{"a":{"b":1}}`, jsonmap.CreateMergePatch(jsonmap.FromString(`{"a":[1]}`), jsonmap.FromString(`{"a":{"b":1}}`)).Stringify())
+}
+
+func BenchmarkCreatePatchArrays(b *testing.B) {
+	from, to := make([]int, 1000), make([]int, 1000)
+	for i := range from {
+		from[i], to[i] = i, i*2
+	}
+	a, _ := jsonmap.FromValue(from)
+	c, _ := jsonmap.FromValue(to)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		jsonmap.CreatePatch(a, c)
+	}
+}
@@ -0,0 +1,247 @@
+package jsonmap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// PathError is returned when a path is malformed, or when a value can't be set at a path
+type PathError struct {
+	Path   string
+	Offset int // byte offset of the error in the path, -1 if the path is not malformed
+	msg    string
+}
+
+// Error implements the error interface
+func (e *PathError) Error() string {
+	if e.Offset < 0 {
+		return fmt.Sprintf("jsonmap: path '%s': %s", e.Path, e.msg)
+	}
+	return fmt.Sprintf("jsonmap: path '%s': %s at offset %d", e.Path, e.msg, e.Offset)
+}
+
+// Path is a parsed path, reusable without parsing it again
+// Example : total := MustCompile("hits.total.value") ; total.Get(j).AsInt()
+type Path struct {
+	path string
+	keys []pathKey
+}
+
+// ParsePath parses a path strictly. Its grammar is :
+//
+//	path    = [ key { "." name | bracket } ]
+//	key     = name | bracket
+//	name    = one or more characters other than ".", "[" and "]", "\." being a literal dot, "*" a wildcard and "\*" the key "*"
+//	bracket = "[" ( index | "*" | "" ) "]", an empty bracket appending to an array
+//
+// Names can't start or end with spaces, which Get and Set trim.
+// Returns a *PathError with the offset of the error if the path is malformed, ie "a..b", "a[1", "a[b]" or "a]".
+// Get, Set and the other methods taking string paths accept malformed paths leniently, as before
+func ParsePath(path string) (Path, error) {
+	keys, err := parsePath(path)
+	if err != nil {
+		return Path{}, err
+	}
+	return Path{path: path, keys: keys}, nil
+}
+
+// Compile parses a path, see ParsePath
+func Compile(path string) (Path, error) {
+	return ParsePath(path)
+}
+
+// MustCompile is like Compile but panics if the path is malformed
+func MustCompile(path string) Path {
+	p, err := Compile(path)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the source of the path
+func (p Path) String() string {
+	return p.path
+}
+
+// Get gets the value at the path of j, see Json.Get
+func (p Path) Get(j *Json) *Json {
+	return j.get(p.path, p.keys)
+}
+
+// Has checks if the path is a direct property of j, see Json.Has
+func (p Path) Has(j *Json) bool {
+	return j.has(p.keys)
+}
+
+// Set sets the value at the path of j, see Json.Set
+func (p Path) Set(j *Json, value interface{}) bool {
+	return j.set(p.keys, value)
+}
+
+// Unset deletes the value at the path of j, see Json.Unset
+func (p Path) Unset(j *Json) bool {
+	return j.unset(p.keys)
+}
+
+// parsePath splits a path into keys according to the grammar of ParsePath
+func parsePath(path string) ([]pathKey, error) {
+	var keys []pathKey
+	i := 0
+	for i < len(path) {
+		// key
+		if path[i] == '[' {
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, &PathError{path, len(path), "missing ']'"}
+			}
+			k, ok := bracketKey(path[i+1 : i+end])
+			if !ok {
+				return nil, &PathError{path, i + 1, fmt.Sprintf("invalid index '%s'", path[i+1:i+end])}
+			}
+			keys = append(keys, k)
+			i += end + 1
+		} else {
+			name, n := pathName(path[i:])
+			if n == 0 {
+				return nil, &PathError{path, i, "expected a key"}
+			}
+			if r, _ := utf8.DecodeRuneInString(path[i:]); unicode.IsSpace(r) {
+				return nil, &PathError{path, i, "unexpected space"}
+			}
+			if r, size := utf8.DecodeLastRuneInString(path[:i+n]); unicode.IsSpace(r) {
+				return nil, &PathError{path, i + n - size, "unexpected space"}
+			}
+			keys = append(keys, nameKey(name))
+			i += n
+		}
+
+		// separator
+		if i == len(path) {
+			break
+		}
+		switch path[i] {
+		case '.':
+			i++
+			if i == len(path) || path[i] == '[' {
+				return nil, &PathError{path, i, "expected a key"}
+			}
+		case '[':
+		case ']':
+			return nil, &PathError{path, i, "unexpected ']'"}
+		default:
+			return nil, &PathError{path, i, "expected '.' or '['"}
+		}
+	}
+	return keys, nil
+}
+
+// pathName reads a name at the start of path, returns the unescaped name and the number of bytes read
+func pathName(path string) (string, int) {
+	var sb strings.Builder
+	i := 0
+	for i < len(path) {
+		c := path[i]
+		if c == '\\' && i+1 < len(path) && path[i+1] == '.' {
+			sb.WriteByte('.')
+			i += 2
+			continue
+		}
+		if c == '.' || c == '[' || c == ']' {
+			break
+		}
+		sb.WriteByte(c)
+		i++
+	}
+	return sb.String(), i
+}
+
+// nameKey parses a name, only canonical integers are indexes, see indexOf
+func nameKey(name string) pathKey {
+	k := newPathKey(name, false)
+	if k.isIndex {
+		k.index, k.isIndex = indexOf(name)
+	}
+	return k
+}
+
+// bracketKey parses the content of a bracket : an index, a wildcard or empty to append
+func bracketKey(s string) (pathKey, bool) {
+	switch s {
+	case "":
+		return pathKey{append: true, bracket: true}, true
+	case "*":
+		return pathKey{name: s, wildcard: true, bracket: true}, true
+	}
+	idx, ok := indexOf(s)
+	if !ok {
+		return pathKey{}, false
+	}
+	return pathKey{name: s, index: idx, isIndex: true, bracket: true}, true
+}
+
+// pathCacheSize is the number of string paths kept parsed in a generation of the cache
+const pathCacheSize = 1024
+
+// parsedPaths caches the string paths given to Get, Set, Has, Unset...
+var parsedPaths = newPathCache(pathCacheSize)
+
+// pathKeys returns the keys of a string path, from the cache
+// The keys are shared and must not be modified
+func pathKeys(path string) []pathKey {
+	return parsedPaths.get(path)
+}
+
+// pathCache is a cache of parsed paths, safe for concurrent use, with lock-free hits.
+// It approximates a LRU cache with two generations : when the current generation is full, it becomes
+// the previous one, and the paths of the previous one which are not used again are evicted
+type pathCache struct {
+	size int
+	gens atomic.Value // *pathGenerations
+	mu   sync.Mutex   // serializes the rotations of generations
+}
+
+// pathGenerations are the generations of a cache
+type pathGenerations struct {
+	current  *sync.Map // path => []pathKey
+	previous *sync.Map
+	count    int64 // number of paths in current
+}
+
+func newPathCache(size int) *pathCache {
+	c := &pathCache{size: size}
+	c.gens.Store(&pathGenerations{current: &sync.Map{}, previous: &sync.Map{}})
+	return c
+}
+
+// get returns the keys of path, from the current generation, else from the previous one, else parsed
+func (c *pathCache) get(path string) []pathKey {
+	g := c.gens.Load().(*pathGenerations)
+	if keys, ok := g.current.Load(path); ok {
+		return keys.([]pathKey)
+	}
+
+	var keys []pathKey
+	if prev, ok := g.previous.Load(path); ok {
+		keys = prev.([]pathKey)
+	} else {
+		keys = createPath(path)
+	}
+	if _, loaded := g.current.LoadOrStore(path, keys); !loaded && atomic.AddInt64(&g.count, 1) >= int64(c.size) {
+		c.rotate(g)
+	}
+	return keys
+}
+
+// rotate replaces the full generations g
+func (c *pathCache) rotate(g *pathGenerations) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.gens.Load().(*pathGenerations) == g {
+		c.gens.Store(&pathGenerations{current: &sync.Map{}, previous: g.current})
+	}
+}
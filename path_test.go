@@ -0,0 +1,197 @@
+package jsonmap_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	j := jsonmap.FromString(`{ "hits": { "total": { "value": 2 }, "hits": [{ "_id": "1" }, { "_id": "2" }] } }`)
+
+	total := jsonmap.MustCompile("hits.total.value")
+	assert.Equal(t, "hits.total.value", total.String())
+	assert.Equal(t, int64(2), total.Get(j).AsInt())
+	assert.Equal(t, "hits.total.value", total.Get(j).Path())
+	assert.True(t, total.Has(j))
+	assert.True(t, total.Set(j, 3))
+	assert.Equal(t, int64(3), j.Get("hits.total.value").AsInt())
+	assert.True(t, total.Unset(j))
+	assert.False(t, total.Has(j))
+	assert.True(t, total.Get(j).IsNil())
+
+	id, err := jsonmap.Compile("_id")
+	assert.NoError(t, err)
+	hits := j.Get("hits.hits")
+	assert.Equal(t, "2", id.Get(hits.Get("[-1]")).AsString())
+	assert.Equal(t, "hits.hits[-1]._id", id.Get(hits.Get("[-1]")).Path())
+
+	ids := jsonmap.MustCompile("hits.hits[*]._id")
+	assert.True(t, ids.Set(j, "x"))
+	assert.Equal(t, `[{"_id":"x"},{"_id":"x"}]`, j.Get("hits.hits").Stringify())
+
+	tags := jsonmap.MustCompile("tags[]")
+	assert.True(t, tags.Set(j, "a"))
+	assert.True(t, tags.Set(j, "b"))
+	assert.Equal(t, `["a","b"]`, j.Get("tags").Stringify())
+
+	// lazy jsons
+	lazy := jsonmap.FromBytesLazy([]byte(`{ "hits": { "total": { "value": 2 } } }`))
+	assert.Equal(t, int64(2), total.Get(lazy).AsInt())
+	assert.True(t, total.Has(lazy))
+}
+
+func TestParsePath(t *testing.T) {
+	j := jsonmap.FromString(`{ "a.b": { "c": [{ "d": 1 }, { "d": 2 }] }, "first name": "john" }`)
+	for path, expected := range map[string]string{
+		"":             j.Stringify(),
+		`a\.b.c[0]`:    `{"d":1}`,
+		`a\.b.c[-1].d`: `2`,
+		`a\.b.c.1.d`:   `2`,
+		`a\.b.c[*].d`:  `1`,
+		`a\.b.*[1].d`:  `2`,
+		"first name":   `"john"`,
+		`a\.b.c[0][0]`: `null`,
+		`a\.b.missing`: `null`,
+	} {
+		p, err := jsonmap.ParsePath(path)
+		assert.NoError(t, err, path)
+		assert.Equal(t, expected, p.Get(j).Stringify(), path)
+		assert.Equal(t, path, p.String())
+	}
+
+	for path, expected := range map[string]string{
+		"a..b":   "expected a key at offset 2",
+		".a":     "expected a key at offset 0",
+		"a.":     "expected a key at offset 2",
+		"a.[0]":  "expected a key at offset 2",
+		"a[1":    "missing ']' at offset 3",
+		"a[b":    "missing ']' at offset 3",
+		"a[b]":   "invalid index 'b' at offset 2",
+		"a[+1]":  "invalid index '+1' at offset 2",
+		"a[ 1 ]": "invalid index ' 1 ' at offset 2",
+		"a]":     "unexpected ']' at offset 1",
+		"a[0]]":  "unexpected ']' at offset 4",
+		"a[0]b":  "expected '.' or '[' at offset 4",
+		"a. b":   "unexpected space at offset 2",
+		"a .b":   "unexpected space at offset 1",
+		" a":     "unexpected space at offset 0",
+		"a.b\t":  "unexpected space at offset 3",
+	} {
+		_, err := jsonmap.ParsePath(path)
+		var pe *jsonmap.PathError
+		if assert.True(t, errors.As(err, &pe), path) {
+			assert.Equal(t, path, pe.Path)
+			assert.EqualError(t, err, "jsonmap: path '"+path+"': "+expected)
+		}
+	}
+
+	_, err := jsonmap.Compile("a[0")
+	assert.EqualError(t, err, "jsonmap: path 'a[0': missing ']' at offset 3")
+	assert.Panics(t, func() { jsonmap.MustCompile("a]") })
+}
+
+func TestMustGet(t *testing.T) {
+	j := jsonmap.FromString(`{ "a": { "b": [1, 2] } }`)
+	assert.Equal(t, int64(2), j.MustGet("a.b[1]").AsInt())
+	assert.True(t, j.MustGet("a.c").IsNil())
+	assert.Panics(t, func() { j.MustGet("a..b") })
+
+	// strict twin of Get
+	s := jsonmap.FromString(`{ "a": { " b": 1, "b": 2 } }`)
+	assert.Equal(t, int64(2), s.Get("a. b").AsInt())
+	assert.Panics(t, func() { s.MustGet("a. b") })
+	assert.Equal(t, int64(2), s.MustGet("a.b").AsInt())
+
+	// lenient
+	assert.Equal(t, int64(2), j.Get("a..b[1").AsInt())
+}
+
+func TestTrySet(t *testing.T) {
+	j := jsonmap.FromString(`{ "a": { "b": [1, 2] } }`)
+	assert.NoError(t, j.TrySet("a.b[]", 3))
+	assert.NoError(t, j.TrySet("a.c.d", true))
+	assert.Equal(t, `{"a":{"b":[1,2,3],"c":{"d":true}}}`, j.Stringify())
+
+	err := j.TrySet("a[b", 1)
+	assert.EqualError(t, err, "jsonmap: path 'a[b': missing ']' at offset 3")
+	err = j.TrySet("a[]", 1)
+	assert.EqualError(t, err, "jsonmap: path 'a[]': can't set the value")
+	var pe *jsonmap.PathError
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, -1, pe.Offset)
+	assert.Equal(t, `{"a":{"b":[1,2,3],"c":{"d":true}}}`, j.Stringify())
+}
+
+func TestPathCache(t *testing.T) {
+	j := jsonmap.New()
+	for i := 0; i < 3000; i++ {
+		assert.True(t, j.Set(fmt.Sprintf("items[%d].id", i), i))
+	}
+	for i := 0; i < 3000; i += 7 {
+		assert.Equal(t, int64(i), j.Get(fmt.Sprintf("items[%d].id", i)).AsInt())
+	}
+
+	// evicted paths
+	for i := 0; i < 3000; i++ {
+		assert.True(t, j.Set(fmt.Sprintf("keys.k%d", i), i))
+	}
+	for i := 0; i < 3000; i += 7 {
+		assert.Equal(t, int64(i), j.Get(fmt.Sprintf("keys.k%d", i)).AsInt())
+		assert.True(t, j.Has(fmt.Sprintf("keys.k%d", i)))
+	}
+}
+
+func TestPathAllocations(t *testing.T) {
+	j := jsonmap.FromString(`{ "aggregations": { "terms": { "buckets": [{ "key": "a", "doc_count": 1 }] } } }`)
+	path := jsonmap.MustCompile("aggregations.terms.buckets")
+	total := jsonmap.MustCompile("aggregations.terms.total")
+
+	// only the result is allocated
+	assert.Equal(t, float64(1), testing.AllocsPerRun(100, func() { path.Get(j) }))
+	assert.Equal(t, float64(1), testing.AllocsPerRun(100, func() { j.Get("aggregations.terms.buckets") }))
+	assert.Equal(t, float64(0), testing.AllocsPerRun(100, func() { j.Has("aggregations.terms.buckets") }))
+	assert.Equal(t, testing.AllocsPerRun(100, func() { total.Set(j, 1) }), testing.AllocsPerRun(100, func() { j.Set("aggregations.terms.total", 1) }))
+	assert.Equal(t, float64(0), testing.AllocsPerRun(100, func() { j.Has("aggregations.terms.buckets[0].key") }))
+
+	// walking wildcards doesn't allocate by visited value
+	missing := jsonmap.MustCompile("aggregations.*.buckets[*].missing")
+	small := testing.AllocsPerRun(100, func() { missing.Has(j) })
+	for i := 0; i < 100; i++ {
+		j.Set("aggregations.terms.buckets[]", map[string]interface{}{"key": i})
+	}
+	assert.Equal(t, small, testing.AllocsPerRun(100, func() { missing.Has(j) }))
+}
+
+func BenchmarkPathCache(b *testing.B) {
+	j := jsonmap.FromString(`{ "aggregations": { "terms": { "buckets": [{ "key": "a", "doc_count": 1 }] } } }`)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			j.Has("aggregations.terms.buckets")
+		}
+	})
+}
+
+func BenchmarkPathCacheIndexes(b *testing.B) {
+	j := jsonmap.New()
+	paths := make([]string, 1000)
+	for i := range paths {
+		paths[i] = fmt.Sprintf("buckets[%d].key", i)
+		j.Set(paths[i], i)
+	}
+	if allocs := testing.AllocsPerRun(100, func() { j.Has(paths[0]) }); allocs != 0 {
+		b.Fatalf("%v allocs per Has", allocs)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			j.Has(paths[i%len(paths)])
+			i++
+		}
+	})
+}
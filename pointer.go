@@ -0,0 +1,132 @@
+package jsonmap
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PointerError is returned when a JSON Pointer (RFC 6901) is malformed
+// or doesn't reference an existing value
+type PointerError struct {
+	Pointer string
+	msg     string
+}
+
+// Error implements the error interface
+func (e *PointerError) Error() string {
+	return fmt.Sprintf("jsonmap: pointer '%s': %s", e.Pointer, e.msg)
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// parsePointer splits a JSON Pointer into unescaped keys
+func parsePointer(pointer string) ([]pathKey, error) {
+	if len(pointer) == 0 {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, &PointerError{pointer, "must start with '/'"}
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	keys := make([]pathKey, len(tokens))
+	for i, t := range tokens {
+		for p := 0; p < len(t); p++ {
+			if t[p] == '~' && (p == len(t)-1 || (t[p+1] != '0' && t[p+1] != '1')) {
+				return nil, &PointerError{pointer, fmt.Sprintf("invalid escape in '%s'", t)}
+			}
+		}
+		keys[i] = newPointerKey(pointerUnescaper.Replace(t))
+	}
+	return keys, nil
+}
+
+// newPointerKey parses a JSON Pointer token
+// Array indexes are only made of digits, without leading zeros
+func newPointerKey(name string) pathKey {
+	if name == "-" {
+		return pathKey{name: name, append: true}
+	}
+	if idx, ok := indexOf(name); ok && idx >= 0 {
+		return pathKey{name: name, index: idx, isIndex: true}
+	}
+	return pathKey{name: name}
+}
+
+// EscapePointer escapes a key to be used as a JSON Pointer token
+// Example : EscapePointer("a/b") => "a~1b"
+func EscapePointer(key string) string {
+	return pointerEscaper.Replace(key)
+}
+
+// GetPointer gets the value referenced by a JSON Pointer (RFC 6901), ie "/hits/hits/0/_source"
+// If not found or if the pointer is malformed, returns Nil() value
+func (j *Json) GetPointer(pointer string) *Json {
+	j.load()
+	res := &Json{coerce: j.coerce}
+	keys, err := parsePointer(pointer)
+	if err != nil {
+		return res
+	}
+	res.path = joinPath(j.path, pointerToPath(keys))
+	if val, ok := getValue(j.data, keys); ok {
+		res.data = val
+	}
+	return res
+}
+
+// HasPointer checks if a JSON Pointer references an existing value
+func (j *Json) HasPointer(pointer string) bool {
+	j.load()
+	keys, err := parsePointer(pointer)
+	if err != nil {
+		return false
+	}
+	_, ok := getValue(j.data, keys)
+	return ok
+}
+
+// SetPointer sets the value referenced by a JSON Pointer.
+// Missing objects are created as with Set, and the "-" token appends the value to an array
+// Example : SetPointer("/tags/-", "new") appends "new" to the tags array
+func (j *Json) SetPointer(pointer string, value interface{}) error {
+	j.modify()
+	keys, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	data, ok := setValue(j.data, keys, toData(value))
+	if !ok {
+		return &PointerError{pointer, "invalid array index"}
+	}
+	j.data = data
+	return nil
+}
+
+// UnsetPointer deletes the value referenced by a JSON Pointer
+// Returns a *PointerError if the value doesn't exist
+func (j *Json) UnsetPointer(pointer string) error {
+	j.modify()
+	keys, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return &PointerError{pointer, "can't unset the root"}
+	}
+	if _, ok := getValue(j.data, keys); !ok {
+		return &PointerError{pointer, "value not found"}
+	}
+	j.data, _ = unsetValue(j.data, keys)
+	return nil
+}
+
+// pointerToPath converts pointer keys into a path, see Path()
+func pointerToPath(keys []pathKey) string {
+	var path string
+	for _, k := range keys {
+		path = keyPath(path, k.name)
+	}
+	return path
+}
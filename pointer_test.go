@@ -0,0 +1,107 @@
+package jsonmap_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+// Example from RFC 6901
+const jsonPointerTest = `
+{
+	"foo": ["bar", "baz"],
+	"": 0,
+	"a/b": 1,
+	"c%d": 2,
+	"e^f": 3,
+	"g|h": 4,
+	"i\\j": 5,
+	"k\"l": 6,
+	" ": 7,
+	"m~n": 8,
+	"[x]": { "y.z": 9 }
+}
+`
+
+func TestGetPointer(t *testing.T) {
+	j := jsonmap.FromString(jsonPointerTest)
+
+	assert.JSONEq(t, j.Stringify(), j.GetPointer("").Stringify())
+	assert.JSONEq(t, `["bar", "baz"]`, j.GetPointer("/foo").Stringify())
+	assert.Equal(t, "bar", j.GetPointer("/foo/0").AsString())
+	assert.Equal(t, int64(0), j.GetPointer("/").AsInt())
+	assert.Equal(t, int64(1), j.GetPointer("/a~1b").AsInt())
+	assert.Equal(t, int64(2), j.GetPointer("/c%d").AsInt())
+	assert.Equal(t, int64(3), j.GetPointer("/e^f").AsInt())
+	assert.Equal(t, int64(4), j.GetPointer("/g|h").AsInt())
+	assert.Equal(t, int64(5), j.GetPointer("/i\\j").AsInt())
+	assert.Equal(t, int64(6), j.GetPointer("/k\"l").AsInt())
+	assert.Equal(t, int64(7), j.GetPointer("/ ").AsInt())
+	assert.Equal(t, int64(8), j.GetPointer("/m~0n").AsInt())
+	assert.Equal(t, int64(9), j.GetPointer("/[x]/y.z").AsInt())
+	assert.Equal(t, `[x].y\.z`, j.GetPointer("/[x]/y.z").Path())
+
+	assert.True(t, j.GetPointer("/foo/2").IsNil())
+	assert.True(t, j.GetPointer("/foo/-").IsNil())
+	assert.True(t, j.GetPointer("/foo/-1").IsNil())
+	assert.True(t, j.GetPointer("/foo/01").IsNil())
+	assert.True(t, j.GetPointer("/foo/*").IsNil())
+	assert.True(t, j.GetPointer("foo").IsNil())
+	assert.True(t, j.GetPointer("/m~2n").IsNil())
+
+	assert.True(t, j.HasPointer("/foo/1"))
+	assert.True(t, j.HasPointer("/"))
+	assert.False(t, j.HasPointer("/bar"))
+	assert.False(t, j.HasPointer("/m~"))
+}
+
+func TestSetPointer(t *testing.T) {
+	j := jsonmap.FromString(jsonPointerTest)
+
+	assert.NoError(t, j.SetPointer("/foo/0", "qux"))
+	assert.NoError(t, j.SetPointer("/foo/-", "quux"))
+	assert.JSONEq(t, `["qux", "baz", "quux"]`, j.Get("foo").Stringify())
+
+	assert.NoError(t, j.SetPointer("/*", "star"))
+	assert.Equal(t, "star", j.GetPointer("/*").AsString())
+	assert.Equal(t, "qux", j.GetPointer("/foo/0").AsString())
+
+	assert.NoError(t, j.SetPointer("/a~1b", 10))
+	assert.Equal(t, int64(10), j.GetPointer("/a~1b").AsInt())
+
+	assert.NoError(t, j.SetPointer("/new/sub.key", true))
+	assert.JSONEq(t, `{ "sub.key": true }`, j.Get("new").Stringify())
+
+	assert.NoError(t, j.SetPointer("/[x]/y.z/deep", 1))
+	assert.JSONEq(t, `{ "y.z": { "deep": 1 } }`, j.GetPointer("/[x]").Stringify())
+
+	var pe *jsonmap.PointerError
+	err := j.SetPointer("/foo/5", 1)
+	assert.True(t, errors.As(err, &pe))
+	assert.Equal(t, "/foo/5", pe.Pointer)
+	assert.EqualError(t, j.SetPointer("foo", 1), "jsonmap: pointer 'foo': must start with '/'")
+
+	assert.NoError(t, j.SetPointer("", "root"))
+	assert.Equal(t, "root", j.AsString())
+}
+
+func TestUnsetPointer(t *testing.T) {
+	j := jsonmap.FromString(jsonPointerTest)
+
+	assert.NoError(t, j.UnsetPointer("/m~0n"))
+	assert.False(t, j.HasPointer("/m~0n"))
+	assert.NoError(t, j.UnsetPointer("/[x]/y.z"))
+	assert.JSONEq(t, `{}`, j.GetPointer("/[x]").Stringify())
+
+	assert.EqualError(t, j.UnsetPointer("/m~0n"), "jsonmap: pointer '/m~0n': value not found")
+	assert.EqualError(t, j.UnsetPointer(""), "jsonmap: pointer '': can't unset the root")
+	assert.EqualError(t, j.UnsetPointer("/a~"), "jsonmap: pointer '/a~': invalid escape in 'a~'")
+}
+
+func TestEscapePointer(t *testing.T) {
+	assert.Equal(t, "a~1b~0c", jsonmap.EscapePointer("a/b~c"))
+	j := jsonmap.FromString(jsonPointerTest)
+	assert.Equal(t, int64(8), j.GetPointer("/"+jsonmap.EscapePointer("m~n")).AsInt())
+}
@@ -0,0 +1,234 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Scanner extracts the values matching paths from a json stream, one at a time,
+// without decoding the rest of the stream. Streams of several jsons, ie NDJSON, are scanned json by json
+// Example : NewScanner(r, []string{"hits.hits[*]"}) streams each hit of a search response
+type Scanner struct {
+	d     *Decoder
+	paths [][]pathKey
+	all   []int // indexes of all the paths
+	stack []scanFrame
+	locs  []scanLoc // location of the current value by depth, the root has none
+	key   []byte    // buffer of the current key
+	err   error
+}
+
+// scanFrame is an object or an array containing matching values
+type scanFrame struct {
+	array  bool
+	index  int   // index of the next item
+	active []int // indexes of the paths matching the container, reused at each depth
+}
+
+// scanLoc is the key or the index of a value in its container
+// Buffers are reused so values are scanned without allocation
+type scanLoc struct {
+	key     []byte
+	index   int
+	isIndex bool
+	active  []int // paths matching the value
+}
+
+// NewScanner creates a scanner of the values matching paths, with the same syntax as Get
+// Wildcards are supported, but not negative indexes as the length of arrays is unknown.
+// Decoder options apply to the matching values, see MaxRecordSize
+func NewScanner(r io.Reader, paths []string, opt ...DecoderOption) (*Scanner, error) {
+	s := &Scanner{d: NewDecoder(r, opt...)}
+	for i, path := range paths {
+		keys := createPath(path)
+		for _, k := range keys {
+			if (k.isIndex && k.index < 0) || k.append {
+				return nil, fmt.Errorf("jsonmap: can't scan '%s', only positive indexes and wildcards are supported", path)
+			}
+		}
+		s.paths = append(s.paths, keys)
+		s.all = append(s.all, i)
+	}
+	return s, nil
+}
+
+// Next returns the next matching value, in stream order. Its Path() is its location, ie "hits.hits[3]".
+// A value inside another matching value is not returned separately.
+// Returns io.EOF at the end of the stream, or a *SyntaxError. Values which are skipped are not fully validated
+func (s *Scanner) Next() (*Json, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	j, err := s.next()
+	if err != nil {
+		s.err = err
+	}
+	return j, err
+}
+
+// Line returns the line of the start of the last matching value, starting at 1
+func (s *Scanner) Line() int {
+	return s.d.Line()
+}
+
+func (s *Scanner) next() (*Json, error) {
+	for {
+		c, err := s.d.skipSpaces()
+		if err == io.EOF && len(s.stack) > 0 {
+			return nil, s.syntaxError("unexpected end of JSON input")
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// root
+		if len(s.stack) == 0 {
+			if j, err := s.value(c, s.all); j != nil || err != nil {
+				return j, err
+			}
+			continue
+		}
+
+		f := &s.stack[len(s.stack)-1]
+		depth := len(s.stack)
+		switch {
+		case c == ',':
+			continue
+
+		case (f.array && c == ']') || (!f.array && c == '}'):
+			s.stack = s.stack[:len(s.stack)-1]
+			continue
+
+		case f.array:
+			i := f.index
+			f.index++
+			l := s.loc(depth)
+			l.index, l.isIndex = i, true
+			if j, err := s.value(c, s.match(f.active, depth, nil, i)); j != nil || err != nil {
+				return j, err
+			}
+
+		case c == '"':
+			key, err := s.readKey()
+			if err != nil {
+				return nil, err
+			}
+			if c, err = s.d.skipSpaces(); err != nil || c != ':' {
+				return nil, s.syntaxError("expected ':' after object key")
+			}
+			if c, err = s.d.skipSpaces(); err != nil {
+				return nil, s.syntaxError("unexpected end of JSON input")
+			}
+			l := s.loc(depth)
+			l.key, l.isIndex = append(l.key[:0], key...), false
+			if j, err := s.value(c, s.match(f.active, depth, key, -1)); j != nil || err != nil {
+				return j, err
+			}
+
+		default:
+			return nil, s.syntaxError(fmt.Sprintf("invalid character %q looking for object key", c))
+		}
+	}
+}
+
+// loc returns the location of the current value at depth
+func (s *Scanner) loc(depth int) *scanLoc {
+	for len(s.locs) <= depth {
+		s.locs = append(s.locs, scanLoc{})
+	}
+	return &s.locs[depth]
+}
+
+// path returns the path of the current value at depth
+func (s *Scanner) path(depth int) string {
+	path := ""
+	for i := 1; i <= depth; i++ {
+		if l := s.locs[i]; l.isIndex {
+			path = indexPath(path, l.index)
+		} else {
+			path = keyPath(path, string(l.key))
+		}
+	}
+	return path
+}
+
+// value handles the current value starting with c, matched by the active paths
+// A matching value is returned, containers with active paths are entered and other values are skipped
+func (s *Scanner) value(c byte, active []int) (*Json, error) {
+	depth := len(s.stack)
+	for _, p := range active {
+		if len(s.paths[p]) == depth {
+			j, err := s.d.record(c)
+			if err != nil {
+				return nil, err
+			}
+			j.path = s.path(depth)
+			return j, nil
+		}
+	}
+
+	if (c == '{' || c == '[') && len(active) > 0 {
+		s.stack = append(s.stack, scanFrame{array: c == '[', active: active})
+		return nil, nil
+	}
+	if err := s.d.scanValue(c, func(byte) {}); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return nil, nil
+}
+
+// match returns the active paths matching a key, or an index if key is nil, of a value at depth
+// The result is kept in the location at depth
+func (s *Scanner) match(active []int, depth int, key []byte, index int) []int {
+	res := s.loc(depth).active[:0]
+	for _, p := range active {
+		keys := s.paths[p]
+		if len(keys) < depth {
+			continue
+		}
+		k := keys[depth-1]
+		matched := k.wildcard
+		if !matched && key != nil {
+			matched = string(key) == k.name
+		}
+		if !matched && key == nil {
+			matched = k.isIndex && k.index == index
+		}
+		if matched {
+			res = append(res, p)
+		}
+	}
+	s.loc(depth).active = res
+	return res
+}
+
+// readKey reads an object key, after its opening quote
+func (s *Scanner) readKey() ([]byte, error) {
+	s.key = append(s.key[:0], '"')
+	escaped := false
+	if err := s.d.scanString(func(c byte) {
+		escaped = escaped || c == '\\'
+		s.key = append(s.key, c)
+	}); err != nil {
+		return nil, s.syntaxError("unexpected end of JSON input")
+	}
+	if !escaped {
+		return s.key[1 : len(s.key)-1], nil
+	}
+	var key string
+	if err := json.Unmarshal(s.key, &key); err != nil {
+		return nil, s.syntaxError(fmt.Sprintf("invalid object key %s", s.key))
+	}
+	return []byte(key), nil
+}
+
+// syntaxError returns a *SyntaxError at the current position of the stream
+func (s *Scanner) syntaxError(msg string) error {
+	return &SyntaxError{
+		msg:    msg,
+		Offset: s.d.offset,
+		Line:   s.d.line,
+		Column: s.d.column,
+	}
+}
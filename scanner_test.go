@@ -0,0 +1,129 @@
+package jsonmap_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+const scrollResponse = `{
+	"took": 3,
+	"hits": {
+		"total": { "value": 3 },
+		"hits": [
+			{ "_id": "1", "_source": { "host": "web-1", "tags": ["a", "b"] } },
+			{ "_id": "2", "_source": { "host": "web-2", "note": "{[\"hits\"]}" } },
+			{ "_id": "3", "_source": { "host": "web-3" } }
+		]
+	},
+	"aggregations": { "hosts": { "buckets": [{ "key": "web-1" }] } }
+}`
+
+func scanAll(t *testing.T, input string, paths ...string) []string {
+	s, err := jsonmap.NewScanner(strings.NewReader(input), paths)
+	if !assert.NoError(t, err) {
+		return nil
+	}
+	var res []string
+	for {
+		j, err := s.Next()
+		if err == io.EOF {
+			return res
+		}
+		if !assert.NoError(t, err) {
+			return res
+		}
+		res = append(res, j.Path()+" = "+j.Stringify())
+	}
+}
+
+func TestScanner(t *testing.T) {
+	assert.Equal(t, []string{
+		`hits.hits[0] = {"_id":"1","_source":{"host":"web-1","tags":["a","b"]}}`,
+		`hits.hits[1] = {"_id":"2","_source":{"host":"web-2","note":"{[\"hits\"]}"}}`,
+		`hits.hits[2] = {"_id":"3","_source":{"host":"web-3"}}`,
+	}, scanAll(t, scrollResponse, "hits.hits[*]"))
+
+	assert.Equal(t, []string{
+		`took = 3`,
+		`hits.total.value = 3`,
+		`hits.hits[0]._source.host = "web-1"`,
+		`hits.hits[1]._source.host = "web-2"`,
+		`hits.hits[2]._source.host = "web-3"`,
+		`aggregations.hosts.buckets[0].key = "web-1"`,
+	}, scanAll(t, scrollResponse, "hits.hits[*]._source.host", "took", "hits.total.value", "aggregations.*.buckets[0].key"))
+
+	// nested matches are returned once
+	assert.Equal(t, []string{
+		`hits.hits[0]._id = "1"`,
+		`hits.hits[1] = {"_id":"2","_source":{"host":"web-2","note":"{[\"hits\"]}"}}`,
+		`hits.hits[2]._id = "3"`,
+	}, scanAll(t, scrollResponse, "hits.hits[1]", "hits.hits[*]._id", "hits.hits[2]._id"))
+
+	assert.Equal(t, []string{`[0].a\.b = 1`, `[1].a\.b = 2`}, scanAll(t, `[{ "a.b": 1 }, { "a.b": 2, "c": [] }]`, `[*].a\.b`))
+	assert.Equal(t, []string{` = {"a":1}`, ` = [2]`}, scanAll(t, "{ \"a\": 1 }\n[2]\n", ""))
+	assert.Empty(t, scanAll(t, scrollResponse, "missing", "hits.hits[5]", "took.value"))
+
+	// NDJSON streams
+	assert.Equal(t, []string{`index._id = "1"`, `index._id = "2"`}, scanAll(t, "{ \"index\": { \"_id\": \"1\" } }\n{ \"a\": 1 }\n{ \"index\": { \"_id\": \"2\" } }\n", "index._id"))
+}
+
+func TestScannerErrors(t *testing.T) {
+	_, err := jsonmap.NewScanner(strings.NewReader(`[]`), []string{"hits[-1]"})
+	assert.EqualError(t, err, "jsonmap: can't scan 'hits[-1]', only positive indexes and wildcards are supported")
+
+	s, err := jsonmap.NewScanner(strings.NewReader("{\n\"hits\": [{ \"a\": x }]}"), []string{"hits[*]"})
+	assert.NoError(t, err)
+	_, err = s.Next()
+	var se *jsonmap.SyntaxError
+	assert.True(t, errors.As(err, &se))
+	assert.Equal(t, 2, se.Line)
+	assert.Equal(t, 17, se.Column)
+	assert.Equal(t, 2, s.Line())
+	_, err2 := s.Next()
+	assert.Equal(t, err, err2)
+
+	for input, msg := range map[string]string{
+		`{ "hits": [1, 2`:    "unexpected end of JSON input",
+		`{ "hits" 1 }`:       "expected ':' after object key",
+		`{ "a": 1, hits: 1}`: "invalid character 'h' looking for object key",
+	} {
+		s, _ = jsonmap.NewScanner(strings.NewReader(input), []string{"hits.a"})
+		_, err = s.Next()
+		assert.True(t, errors.As(err, &se), input)
+		assert.Contains(t, err.Error(), msg, input)
+	}
+}
+
+func TestScannerAllocations(t *testing.T) {
+	doc := func(n int) []byte {
+		var buf bytes.Buffer
+		buf.WriteString(`{ "hits": { "hits": [`)
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			fmt.Fprintf(&buf, `{ "_id": "%d", "_source": { "message": "skipped \"value\"", "n": [%d, true, null] } }`, i, i)
+		}
+		buf.WriteString(`] }, "took": 1 }`)
+		return buf.Bytes()
+	}
+	scan := func(data []byte) func() {
+		return func() {
+			s, _ := jsonmap.NewScanner(bytes.NewReader(data), []string{"hits.hits[*]._source.missing", "took"})
+			for {
+				if _, err := s.Next(); err != nil {
+					break
+				}
+			}
+		}
+	}
+	small, large := doc(10), doc(1000)
+	assert.Equal(t, testing.AllocsPerRun(10, scan(small)), testing.AllocsPerRun(10, scan(large)))
+}
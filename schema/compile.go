@@ -0,0 +1,478 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/datasweet/jsonmap"
+)
+
+// node is a compiled schema
+type node struct {
+	pointer string // location in the schema document
+	always  *bool  // boolean schema
+
+	// core
+	ref     string
+	refNode *node
+
+	// applicators
+	allOf, anyOf, oneOf  []*node
+	not                  *node
+	ifNode               *node
+	thenNode, elseNode   *node
+	properties           map[string]*node
+	propertyOrder        []string // sorted names of properties
+	patternProperties    []patternNode
+	additionalProperties *node
+	propertyNames        *node
+	dependentSchemas     map[string]*node
+	prefixItems          []*node
+	items                *node
+	contains             *node
+
+	// validation
+	types             []string
+	enum              []*jsonmap.Json
+	constant          *jsonmap.Json
+	multipleOf        *number
+	maximum           *number
+	exclusiveMaximum  *number
+	minimum           *number
+	exclusiveMinimum  *number
+	maxLength         int // -1 if not set
+	minLength         int
+	pattern           *regexp.Regexp
+	maxItems          int // -1 if not set
+	minItems          int
+	uniqueItems       bool
+	maxContains       int // -1 if not set
+	minContains       int // 1 if not set
+	maxProperties     int // -1 if not set
+	minProperties     int
+	required          []string
+	dependentRequired map[string][]string
+}
+
+// patternNode is a schema of patternProperties
+type patternNode struct {
+	re   *regexp.Regexp
+	node *node
+}
+
+// number is a numeric keyword, kept with its text for messages
+type number struct {
+	rat  *big.Rat
+	text string
+}
+
+// jsonTypes are the names accepted by the type keyword
+var jsonTypes = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "string": true, "integer": true,
+}
+
+// compiler compiles the schemas of a document
+type compiler struct {
+	doc     *jsonmap.Json
+	nodes   map[string]*node // compiled schemas by pointer
+	anchors map[string]*node
+	refs    []*node // schemas with a $ref to resolve
+}
+
+func newCompiler(doc *jsonmap.Json) *compiler {
+	return &compiler{
+		doc:     doc,
+		nodes:   make(map[string]*node),
+		anchors: make(map[string]*node),
+	}
+}
+
+// members returns the members of an object
+func members(j *jsonmap.Json) map[string]*jsonmap.Json {
+	m := make(map[string]*jsonmap.Json)
+	j.ForEach(func(k string, v *jsonmap.Json) bool {
+		m[k] = v
+		return true
+	})
+	return m
+}
+
+// childPointer returns the pointer of a member or an item
+func childPointer(pointer string, k string) string {
+	return pointer + "/" + jsonmap.EscapePointer(k)
+}
+
+func schemaError(pointer string, format string, args ...interface{}) error {
+	return &SchemaError{Pointer: pointer, msg: fmt.Sprintf(format, args...)}
+}
+
+// compile compiles the schema j located at pointer in the document
+func (c *compiler) compile(j *jsonmap.Json, pointer string) (*node, error) {
+	if n, ok := c.nodes[pointer]; ok {
+		return n, nil
+	}
+	n := &node{
+		pointer:       pointer,
+		maxLength:     -1,
+		maxItems:      -1,
+		maxContains:   -1,
+		minContains:   1,
+		maxProperties: -1,
+	}
+	c.nodes[pointer] = n
+
+	switch j.Kind() {
+	case jsonmap.BoolKind:
+		b, _ := j.Bool()
+		n.always = &b
+		return n, nil
+	case jsonmap.ObjectKind:
+	default:
+		return nil, schemaError(pointer, "must be an object or a boolean")
+	}
+
+	kw := members(j)
+	for _, k := range []string{"$dynamicRef", "$recursiveRef"} {
+		if _, ok := kw[k]; ok {
+			return nil, schemaError(childPointer(pointer, k), "unsupported keyword")
+		}
+	}
+
+	// core
+	if v, ok := kw["$ref"]; ok {
+		ref, err := v.String()
+		if err != nil {
+			return nil, schemaError(childPointer(pointer, "$ref"), "must be a string")
+		}
+		n.ref = ref
+		c.refs = append(c.refs, n)
+	}
+	if v, ok := kw["$anchor"]; ok {
+		anchor, err := v.String()
+		if err != nil || len(anchor) == 0 {
+			return nil, schemaError(childPointer(pointer, "$anchor"), "must be a non-empty string")
+		}
+		if _, exists := c.anchors[anchor]; exists {
+			return nil, schemaError(childPointer(pointer, "$anchor"), "duplicate anchor '%s'", anchor)
+		}
+		c.anchors[anchor] = n
+	}
+	for _, k := range []string{"$defs", "definitions"} {
+		if _, err := c.schemaMap(kw, k, pointer); err != nil {
+			return nil, err
+		}
+	}
+
+	// applicators
+	var err error
+	if n.allOf, err = c.schemaArray(kw, "allOf", pointer); err != nil {
+		return nil, err
+	}
+	if n.anyOf, err = c.schemaArray(kw, "anyOf", pointer); err != nil {
+		return nil, err
+	}
+	if n.oneOf, err = c.schemaArray(kw, "oneOf", pointer); err != nil {
+		return nil, err
+	}
+	if n.prefixItems, err = c.schemaArray(kw, "prefixItems", pointer); err != nil {
+		return nil, err
+	}
+	for _, s := range []struct {
+		k   string
+		dst **node
+	}{
+		{"not", &n.not},
+		{"if", &n.ifNode},
+		{"then", &n.thenNode},
+		{"else", &n.elseNode},
+		{"additionalProperties", &n.additionalProperties},
+		{"propertyNames", &n.propertyNames},
+		{"items", &n.items},
+		{"contains", &n.contains},
+	} {
+		if v, ok := kw[s.k]; ok {
+			if *s.dst, err = c.compile(v, childPointer(pointer, s.k)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if n.properties, err = c.schemaMap(kw, "properties", pointer); err != nil {
+		return nil, err
+	}
+	n.propertyOrder = sortedNames(n.properties)
+	if n.dependentSchemas, err = c.schemaMap(kw, "dependentSchemas", pointer); err != nil {
+		return nil, err
+	}
+	patterns, err := c.schemaMap(kw, "patternProperties", pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, expr := range sortedNames(patterns) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, schemaError(childPointer(childPointer(pointer, "patternProperties"), expr), "invalid pattern: %s", err)
+		}
+		n.patternProperties = append(n.patternProperties, patternNode{re: re, node: patterns[expr]})
+	}
+
+	// validation
+	if err := c.compileValidation(n, kw); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// compileValidation compiles the validation keywords of n
+func (c *compiler) compileValidation(n *node, kw map[string]*jsonmap.Json) error {
+	pointer := n.pointer
+	if v, ok := kw["type"]; ok {
+		p := childPointer(pointer, "type")
+		if s, err := v.String(); err == nil {
+			n.types = []string{s}
+		} else {
+			names, err := stringArray(v)
+			if err != nil || len(names) == 0 {
+				return schemaError(p, "must be a string or an array of strings")
+			}
+			n.types = names
+		}
+		for _, t := range n.types {
+			if !jsonTypes[t] {
+				return schemaError(p, "unknown type '%s'", t)
+			}
+		}
+	}
+	if v, ok := kw["enum"]; ok {
+		if !v.IsArray() {
+			return schemaError(childPointer(pointer, "enum"), "must be an array")
+		}
+		n.enum = v.Values()
+	}
+	if v, ok := kw["const"]; ok {
+		n.constant = v
+	}
+
+	for _, s := range []struct {
+		k   string
+		dst **number
+	}{
+		{"multipleOf", &n.multipleOf},
+		{"maximum", &n.maximum},
+		{"exclusiveMaximum", &n.exclusiveMaximum},
+		{"minimum", &n.minimum},
+		{"exclusiveMinimum", &n.exclusiveMinimum},
+	} {
+		if v, ok := kw[s.k]; ok {
+			num, ok := numberOf(v)
+			if !ok {
+				return schemaError(childPointer(pointer, s.k), "must be a number")
+			}
+			*s.dst = &number{rat: num, text: v.Stringify()}
+		}
+	}
+	if n.multipleOf != nil && n.multipleOf.rat.Sign() <= 0 {
+		return schemaError(childPointer(pointer, "multipleOf"), "must be strictly greater than 0")
+	}
+
+	for _, s := range []struct {
+		k   string
+		dst *int
+	}{
+		{"maxLength", &n.maxLength},
+		{"minLength", &n.minLength},
+		{"maxItems", &n.maxItems},
+		{"minItems", &n.minItems},
+		{"maxContains", &n.maxContains},
+		{"minContains", &n.minContains},
+		{"maxProperties", &n.maxProperties},
+		{"minProperties", &n.minProperties},
+	} {
+		if v, ok := kw[s.k]; ok {
+			count, ok := countOf(v)
+			if !ok {
+				return schemaError(childPointer(pointer, s.k), "must be a non-negative integer")
+			}
+			*s.dst = count
+		}
+	}
+
+	if v, ok := kw["pattern"]; ok {
+		p := childPointer(pointer, "pattern")
+		expr, err := v.String()
+		if err != nil {
+			return schemaError(p, "must be a string")
+		}
+		if n.pattern, err = regexp.Compile(expr); err != nil {
+			return schemaError(p, "invalid pattern: %s", err)
+		}
+	}
+	if v, ok := kw["uniqueItems"]; ok {
+		b, err := v.Bool()
+		if err != nil {
+			return schemaError(childPointer(pointer, "uniqueItems"), "must be a boolean")
+		}
+		n.uniqueItems = b
+	}
+	if v, ok := kw["required"]; ok {
+		names, err := stringArray(v)
+		if err != nil {
+			return schemaError(childPointer(pointer, "required"), "must be an array of strings")
+		}
+		n.required = names
+	}
+	if v, ok := kw["dependentRequired"]; ok {
+		p := childPointer(pointer, "dependentRequired")
+		if !v.IsObject() {
+			return schemaError(p, "must be an object")
+		}
+		n.dependentRequired = make(map[string][]string)
+		deps := members(v)
+		for _, name := range v.SortedKeys() {
+			names, err := stringArray(deps[name])
+			if err != nil {
+				return schemaError(childPointer(p, name), "must be an array of strings")
+			}
+			n.dependentRequired[name] = names
+		}
+	}
+	return nil
+}
+
+// schemaArray compiles a keyword holding a non-empty array of schemas
+func (c *compiler) schemaArray(kw map[string]*jsonmap.Json, k string, pointer string) ([]*node, error) {
+	v, ok := kw[k]
+	if !ok {
+		return nil, nil
+	}
+	p := childPointer(pointer, k)
+	items := v.Values()
+	if !v.IsArray() || len(items) == 0 {
+		return nil, schemaError(p, "must be a non-empty array")
+	}
+	nodes := make([]*node, len(items))
+	for i, item := range items {
+		n, err := c.compile(item, childPointer(p, strconv.Itoa(i)))
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = n
+	}
+	return nodes, nil
+}
+
+// schemaMap compiles a keyword holding an object of schemas
+func (c *compiler) schemaMap(kw map[string]*jsonmap.Json, k string, pointer string) (map[string]*node, error) {
+	v, ok := kw[k]
+	if !ok {
+		return nil, nil
+	}
+	p := childPointer(pointer, k)
+	if !v.IsObject() {
+		return nil, schemaError(p, "must be an object")
+	}
+	nodes := make(map[string]*node)
+	schemas := members(v)
+	for _, name := range v.SortedKeys() {
+		n, err := c.compile(schemas[name], childPointer(p, name))
+		if err != nil {
+			return nil, err
+		}
+		nodes[name] = n
+	}
+	return nodes, nil
+}
+
+// resolve resolves the $ref of the compiled schemas
+// References must be in the document : "#", "#/json/pointer" or "#anchor"
+func (c *compiler) resolve() error {
+	for i := 0; i < len(c.refs); i++ { // refs can grow while resolving
+		n := c.refs[i]
+		p := childPointer(n.pointer, "$ref")
+		if !strings.HasPrefix(n.ref, "#") {
+			return schemaError(p, "unsupported reference '%s', only references in the document are supported", n.ref)
+		}
+		fragment, err := url.PathUnescape(n.ref[1:])
+		if err != nil {
+			return schemaError(p, "invalid reference '%s'", n.ref)
+		}
+
+		if len(fragment) > 0 && fragment[0] != '/' {
+			target, ok := c.anchors[fragment]
+			if !ok {
+				return schemaError(p, "unknown anchor '%s'", fragment)
+			}
+			n.refNode = target
+			continue
+		}
+		if target, ok := c.nodes[fragment]; ok {
+			n.refNode = target
+			continue
+		}
+		if !c.doc.HasPointer(fragment) {
+			return schemaError(p, "reference '%s' not found", n.ref)
+		}
+		if n.refNode, err = c.compile(c.doc.GetPointer(fragment), fragment); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortedNames returns the sorted names of an object of schemas
+func sortedNames(nodes map[string]*node) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// stringArray returns the strings of an array
+func stringArray(j *jsonmap.Json) ([]string, error) {
+	items, err := j.Array()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, len(items))
+	for i, item := range j.Values() {
+		if names[i], err = item.String(); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// numberOf returns the exact value of a number
+func numberOf(j *jsonmap.Json) (*big.Rat, bool) {
+	var text string
+	switch v := j.Data().(type) {
+	case json.Number:
+		text = v.String()
+	case float64:
+		text = strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		text = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		text = fmt.Sprint(v)
+	default:
+		return nil, false
+	}
+	return new(big.Rat).SetString(text)
+}
+
+// countOf returns the value of a non-negative integer
+func countOf(j *jsonmap.Json) (int, bool) {
+	r, ok := numberOf(j)
+	if !ok || !r.IsInt() || r.Sign() < 0 || !r.Num().IsInt64() || r.Num().Int64() > math.MaxInt32 {
+		return 0, false
+	}
+	return int(r.Num().Int64()), true
+}
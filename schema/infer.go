@@ -0,0 +1,304 @@
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/datasweet/jsonmap"
+)
+
+// draft is the $schema of inferred schemas
+const draft = "https://json-schema.org/draft/2020-12/schema"
+
+// typeOrder is the order of the types of inferred schemas and fields
+var typeOrder = []string{"object", "array", "string", "number", "integer", "boolean", "null"}
+
+// InferOptions are our Inferrer options
+type InferOptions struct {
+	MaxEnum int
+}
+
+// InferOption is an inferrer option setter
+type InferOption func(o *InferOptions)
+
+func newInferOptions(opt ...InferOption) InferOptions {
+	opts := InferOptions{}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return opts
+}
+
+// MaxEnum infers an enum for strings with at most max distinct values
+// default : 0, no enum
+func MaxEnum(max int) InferOption {
+	return func(opts *InferOptions) {
+		opts.MaxEnum = max
+	}
+}
+
+// Inferrer infers a schema from sample jsons, see Infer
+type Inferrer struct {
+	opts InferOptions
+	root *shape
+}
+
+// NewInferrer creates an inferrer without samples
+func NewInferrer(opt ...InferOption) *Inferrer {
+	return &Inferrer{opts: newInferOptions(opt...), root: newShape()}
+}
+
+// Infer infers a JSON Schema describing all the samples
+// Example : Infer(FromString(`{ "a": 1 }`), FromString(`{ "a": null, "b": "x" }`))
+//
+//	=> { "type": "object", "properties": { "a": { "type": ["integer", "null"] }, "b": { "type": "string" } }, "required": ["a"] }
+func Infer(samples ...*jsonmap.Json) *jsonmap.Json {
+	in := NewInferrer()
+	in.Add(samples...)
+	return in.Schema()
+}
+
+// Add observes samples, nil samples are skipped
+func (in *Inferrer) Add(samples ...*jsonmap.Json) {
+	for _, s := range samples {
+		if s != nil {
+			in.root.observe(s, in.opts.MaxEnum)
+		}
+	}
+}
+
+// Schema returns the JSON Schema (draft 2020-12) describing the samples :
+// types, properties present in all objects as required, array items and enums, see MaxEnum.
+// Returns an empty schema, valid for any json, without samples
+func (in *Inferrer) Schema() *jsonmap.Json {
+	s := in.root.schema()
+	s["$schema"] = draft
+	return jsonmap.FromMap(s)
+}
+
+// Field is an inferred field
+type Field struct {
+	Path     string   // path of the field, array items use a wildcard, ie "hits.hits[*]._id"
+	Types    []string // observed types, ie ["string", "null"]
+	Optional bool     // missing in some objects
+	Enum     []string // observed values of strings, see MaxEnum
+}
+
+// String returns a compact summary of the field, ie "hits.hits[*]._id?: string|null"
+func (f Field) String() string {
+	var sb strings.Builder
+	sb.WriteString(f.Path)
+	if f.Optional {
+		sb.WriteByte('?')
+	}
+	sb.WriteString(": ")
+	sb.WriteString(strings.Join(f.Types, "|"))
+	if len(f.Enum) > 0 {
+		sb.WriteString(fmt.Sprintf(" %q", f.Enum))
+	}
+	return sb.String()
+}
+
+// Fields returns the inferred fields of the samples, depth-first in the order they were first observed
+// The root is not a field. Comparing the fields of two inferrers shows new or changed fields
+func (in *Inferrer) Fields() []Field {
+	var fields []Field
+	in.root.fields("", &fields)
+	return fields
+}
+
+// shape accumulates the observed values at a location
+type shape struct {
+	types map[string]bool
+
+	// objects
+	objects    int               // observed objects
+	properties map[string]*shape // by name
+	order      []string          // names in observed order
+	seen       map[string]int    // objects having the property
+
+	// arrays
+	items *shape
+
+	// strings
+	values map[string]bool // distinct values, nil when there are too many
+}
+
+func newShape() *shape {
+	return &shape{
+		types:  make(map[string]bool),
+		values: make(map[string]bool),
+	}
+}
+
+// observe adds a value to the shape
+func (s *shape) observe(j *jsonmap.Json, maxEnum int) {
+	switch j.Kind() {
+	case jsonmap.ObjectKind:
+		s.types["object"] = true
+		s.objects++
+		if s.properties == nil {
+			s.properties = make(map[string]*shape)
+			s.seen = make(map[string]int)
+		}
+		forEach := j.ForEachSorted
+		if j.IsOrdered() {
+			forEach = j.ForEach // keeps the order of the keys
+		}
+		forEach(func(k string, v *jsonmap.Json) bool {
+			p, ok := s.properties[k]
+			if !ok {
+				p = newShape()
+				s.properties[k] = p
+				s.order = append(s.order, k)
+			}
+			s.seen[k]++
+			p.observe(v, maxEnum)
+			return true
+		})
+
+	case jsonmap.ArrayKind:
+		s.types["array"] = true
+		if s.items == nil {
+			s.items = newShape()
+		}
+		for _, item := range j.Values() {
+			s.items.observe(item, maxEnum)
+		}
+
+	case jsonmap.StringKind:
+		s.types["string"] = true
+		v, _ := j.String()
+		if s.values != nil && !s.values[v] {
+			if len(s.values) >= maxEnum {
+				s.values = nil
+			} else {
+				s.values[v] = true
+			}
+		}
+
+	case jsonmap.NumberKind:
+		if isInteger(j) {
+			s.types["integer"] = true
+		} else {
+			s.types["number"] = true
+		}
+
+	case jsonmap.BoolKind:
+		s.types["boolean"] = true
+
+	case jsonmap.NullKind:
+		s.types["null"] = true
+	}
+}
+
+// typeNames returns the observed types, integer is merged into number if both were observed
+func (s *shape) typeNames() []string {
+	var names []string
+	for _, t := range typeOrder {
+		if s.types[t] && !(t == "integer" && s.types["number"]) {
+			names = append(names, t)
+		}
+	}
+	return names
+}
+
+// enum returns the sorted observed strings if they are an enum
+func (s *shape) enum() []string {
+	if !s.types["string"] || len(s.values) == 0 {
+		return nil
+	}
+	values := make([]string, 0, len(s.values))
+	for v := range s.values {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// required returns the sorted names of the properties present in all objects
+func (s *shape) required() []string {
+	var names []string
+	for k, n := range s.seen {
+		if n == s.objects {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// schema returns the JSON Schema of the shape
+func (s *shape) schema() map[string]interface{} {
+	res := make(map[string]interface{})
+	types := s.typeNames()
+	switch len(types) {
+	case 0:
+		return res
+	case 1:
+		res["type"] = types[0]
+	default:
+		res["type"] = interfaces(types)
+	}
+
+	if s.properties != nil {
+		properties := make(map[string]interface{}, len(s.properties))
+		for k, p := range s.properties {
+			properties[k] = p.schema()
+		}
+		res["properties"] = properties
+		if required := s.required(); len(required) > 0 {
+			res["required"] = interfaces(required)
+		}
+	}
+	if s.items != nil && len(s.items.types) > 0 {
+		res["items"] = s.items.schema()
+	}
+	if enum := s.enum(); enum != nil {
+		values := interfaces(enum)
+		if s.types["null"] {
+			values = append(values, nil)
+		}
+		if len(types) == 1 || (len(types) == 2 && s.types["null"]) {
+			res["enum"] = values
+		}
+	}
+	return res
+}
+
+// interfaces converts strings to json array items
+func interfaces(strs []string) []interface{} {
+	items := make([]interface{}, len(strs))
+	for i, s := range strs {
+		items[i] = s
+	}
+	return items
+}
+
+// fields appends the fields of the shape located at path
+func (s *shape) fields(path string, fields *[]Field) {
+	for _, k := range s.order {
+		p := s.properties[k]
+		fp := jsonmap.EscapePath(k)
+		if len(path) > 0 {
+			fp = path + "." + fp
+		}
+		*fields = append(*fields, Field{
+			Path:     fp,
+			Types:    p.typeNames(),
+			Optional: s.seen[k] < s.objects,
+			Enum:     p.enum(),
+		})
+		p.fields(fp, fields)
+	}
+	if s.items != nil && len(s.items.types) > 0 {
+		ip := path + "[*]"
+		*fields = append(*fields, Field{
+			Path:  ip,
+			Types: s.items.typeNames(),
+			Enum:  s.items.enum(),
+		})
+		s.items.fields(ip, fields)
+	}
+}
@@ -0,0 +1,114 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/datasweet/jsonmap/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+var hitSamples = []string{
+	`{ "_id": "1", "_score": 1.5, "_source": { "host": "web-1", "level": "error", "tags": ["prod"], "latency": 12 } }`,
+	`{ "_id": "2", "_score": 2, "_source": { "host": "web-2", "level": "info", "tags": [], "latency": null } }`,
+	`{ "_id": "3", "_score": null, "_source": { "host": "web-1", "level": "error", "user": { "id": 1 } } }`,
+}
+
+func samples(docs ...string) []*jsonmap.Json {
+	res := make([]*jsonmap.Json, len(docs))
+	for i, d := range docs {
+		res[i] = jsonmap.FromString(d)
+	}
+	return res
+}
+
+func TestInfer(t *testing.T) {
+	s := schema.Infer(samples(hitSamples...)...)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": "object",
+		"properties": {
+			"_id": { "type": "string" },
+			"_score": { "type": ["number", "null"] },
+			"_source": {
+				"type": "object",
+				"properties": {
+					"host": { "type": "string" },
+					"level": { "type": "string" },
+					"tags": { "type": "array", "items": { "type": "string" } },
+					"latency": { "type": ["integer", "null"] },
+					"user": { "type": "object", "properties": { "id": { "type": "integer" } }, "required": ["id"] }
+				},
+				"required": ["host", "level"]
+			}
+		},
+		"required": ["_id", "_score", "_source"]
+	}`, s.Stringify())
+
+	// the samples are valid against the inferred schema
+	sch, err := schema.Compile(s)
+	assert.NoError(t, err)
+	for _, sample := range samples(hitSamples...) {
+		assert.NoError(t, sch.Validate(sample))
+	}
+	assert.Error(t, sch.Validate(jsonmap.FromString(`{ "_id": 4, "_score": 1, "_source": {} }`)))
+
+	assert.JSONEq(t, `{ "$schema": "https://json-schema.org/draft/2020-12/schema" }`, schema.Infer().Stringify())
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"type": ["array", "string"],
+		"items": { "type": ["object", "boolean"], "properties": { "a": { "type": "null" } }, "required": ["a"] }
+	}`, schema.Infer(samples(`[{ "a": null }, true]`, `"text"`, `[]`)...).Stringify())
+}
+
+func TestInferEnum(t *testing.T) {
+	in := schema.NewInferrer(schema.MaxEnum(2))
+	in.Add(samples(hitSamples...)...)
+	in.Add(jsonmap.FromString(`{ "_id": "4", "_score": 1, "_source": { "host": "web-3", "level": null } }`))
+
+	s := in.Schema()
+	assert.JSONEq(t, `{ "type": ["string", "null"], "enum": ["error", "info", null] }`, s.Get("properties._source.properties.level").Stringify())
+	assert.JSONEq(t, `{ "type": "string" }`, s.Get("properties._source.properties.host").Stringify())
+	assert.JSONEq(t, `{ "type": "string", "enum": ["prod"] }`, s.Get("properties._source.properties.tags.items").Stringify())
+}
+
+func TestInferFields(t *testing.T) {
+	in := schema.NewInferrer(schema.MaxEnum(2))
+	in.Add(samples(hitSamples...)...)
+
+	fields := make([]string, 0)
+	for _, f := range in.Fields() {
+		fields = append(fields, f.String())
+	}
+	assert.Equal(t, []string{
+		"_id: string",
+		"_score: number|null",
+		"_source: object",
+		`_source.host: string ["web-1" "web-2"]`,
+		`_source.latency?: integer|null`,
+		`_source.level: string ["error" "info"]`,
+		`_source.tags?: array`,
+		`_source.tags[*]: string ["prod"]`,
+		"_source.user?: object",
+		"_source.user.id: integer",
+	}, fields)
+
+	assert.Equal(t, schema.Field{Path: "_source.tags[*]", Types: []string{"string"}, Enum: []string{"prod"}}, in.Fields()[7])
+
+	// ordered samples keep the order of their keys
+	ordered, err := jsonmap.ParseString(`[{ "b.c": 1, "a": [[true]] }]`, jsonmap.Ordered(true))
+	assert.NoError(t, err)
+	in = schema.NewInferrer()
+	in.Add(ordered)
+	fields = fields[:0]
+	for _, f := range in.Fields() {
+		fields = append(fields, f.String())
+	}
+	assert.Equal(t, []string{
+		"[*]: object",
+		`[*].b\.c: integer`,
+		"[*].a: array",
+		"[*].a[*]: array",
+		"[*].a[*][*]: boolean",
+	}, fields)
+}
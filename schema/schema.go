@@ -0,0 +1,106 @@
+// Package schema validates jsons against a JSON Schema (draft 2020-12),
+// and infers schemas from sample jsons, see Infer.
+//
+// Supported keywords :
+//   - core : $ref (within the document, to a JSON Pointer or an $anchor), $anchor, $defs
+//   - applicators : allOf, anyOf, oneOf, not, if, then, else, properties, patternProperties,
+//     additionalProperties, propertyNames, dependentSchemas, prefixItems, items, contains
+//   - validation : type, enum, const, multipleOf, maximum, exclusiveMaximum, minimum, exclusiveMinimum,
+//     maxLength, minLength, pattern, maxItems, minItems, uniqueItems, maxContains, minContains,
+//     maxProperties, minProperties, required, dependentRequired
+//
+// Other keywords, ie format or title, are ignored.
+// Patterns are Go regular expressions (RE2), which covers most ECMA-262 expressions used in schemas.
+package schema
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/datasweet/jsonmap"
+)
+
+// Schema is a compiled JSON Schema, safe for concurrent use
+type Schema struct {
+	root *node
+}
+
+// Compile compiles a JSON Schema
+// Returns a *SchemaError if the schema is invalid or uses an unsupported feature, ie a remote $ref
+// Example : Compile(jsonmap.FromString(`{ "type": "object", "required": ["title"] }`))
+func Compile(j *jsonmap.Json) (*Schema, error) {
+	if j == nil {
+		j = jsonmap.Nil()
+	}
+	doc := j.Clone() // the schema keeps enum and const values
+	c := newCompiler(doc)
+	root, err := c.compile(doc, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.resolve(); err != nil {
+		return nil, err
+	}
+	return &Schema{root: root}, nil
+}
+
+// MustCompile is like Compile but panics if the schema is invalid
+func MustCompile(j *jsonmap.Json) *Schema {
+	s, err := Compile(j)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Validate validates a json against the schema
+// Returns a *ValidationError with every violation, or nil if the json is valid
+func (s *Schema) Validate(j *jsonmap.Json) error {
+	if j == nil {
+		j = jsonmap.Nil()
+	}
+	v := &validator{}
+	v.validate(s.root, j, "", 0)
+	if len(v.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: v.violations}
+}
+
+// SchemaError is returned by Compile when a schema is invalid
+type SchemaError struct {
+	Pointer string // JSON Pointer of the invalid keyword in the schema
+	msg     string
+}
+
+// Error implements the error interface
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("jsonmap: schema: invalid '#%s': %s", e.Pointer, e.msg)
+}
+
+// Violation is a value failing a keyword of the schema
+type Violation struct {
+	Pointer        string // JSON Pointer of the invalid value, ie "/panels/0/type"
+	Keyword        string // failing keyword, ie "enum"
+	KeywordPointer string // JSON Pointer of the keyword in the schema, ie "/properties/panels/items/properties/type/enum"
+	Message        string
+}
+
+// String returns a readable violation, ie "#/panels/0/type: enum: must be one of ["graph","table"]"
+func (v Violation) String() string {
+	return fmt.Sprintf("#%s: %s: %s", v.Pointer, v.Keyword, v.Message)
+}
+
+// ValidationError is returned by Validate with every violation
+type ValidationError struct {
+	Violations []Violation
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return "jsonmap: schema: " + strings.Join(msgs, ", ")
+}
@@ -0,0 +1,322 @@
+package schema_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/datasweet/jsonmap/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+const dashboardSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["title", "panels"],
+	"properties": {
+		"title": { "type": "string", "minLength": 1, "maxLength": 20 },
+		"refresh": { "type": "string", "pattern": "^[0-9]+[smh]$" },
+		"tags": { "type": "array", "items": { "type": "string" }, "uniqueItems": true },
+		"panels": { "type": "array", "minItems": 1, "items": { "$ref": "#/$defs/panel" } }
+	},
+	"additionalProperties": false,
+	"$defs": {
+		"panel": {
+			"type": "object",
+			"required": ["type"],
+			"properties": {
+				"type": { "enum": ["graph", "table", "text"] },
+				"span": { "type": "integer", "minimum": 1, "maximum": 12 },
+				"query": { "$ref": "#/$defs/query" }
+			}
+		},
+		"query": {
+			"oneOf": [
+				{ "type": "string" },
+				{ "type": "object", "required": ["index"] }
+			]
+		}
+	}
+}`
+
+func compile(t *testing.T, s string) *schema.Schema {
+	sch, err := schema.Compile(jsonmap.FromString(s))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return sch
+}
+
+func violations(err error) []string {
+	var ve *schema.ValidationError
+	if !errors.As(err, &ve) {
+		return nil
+	}
+	res := make([]string, len(ve.Violations))
+	for i, v := range ve.Violations {
+		res[i] = v.String()
+	}
+	return res
+}
+
+func TestValidate(t *testing.T) {
+	sch := compile(t, dashboardSchema)
+
+	valid := jsonmap.FromString(`{
+		"title": "Logs",
+		"refresh": "30s",
+		"tags": ["prod", "web"],
+		"panels": [
+			{ "type": "graph", "span": 6, "query": "status:500" },
+			{ "type": "table", "span": 6.0, "query": { "index": "logs-*" } }
+		]
+	}`)
+	assert.NoError(t, sch.Validate(valid))
+
+	invalid := jsonmap.FromString(`{
+		"title": "",
+		"refresh": "often",
+		"tags": ["prod", "prod"],
+		"panels": [
+			{ "type": "pie", "span": 13 },
+			{ "span": 1.5, "query": { "size": 1 } }
+		],
+		"owner": "me"
+	}`)
+	err := sch.Validate(invalid)
+	assert.Equal(t, []string{
+		"#/owner: additionalProperties: property 'owner' is not allowed",
+		"#/panels/0/span: maximum: must be <= 12",
+		`#/panels/0/type: enum: must be one of ["graph","table","text"]`,
+		"#/panels/1/query: oneOf: must match exactly one schema, matched 0",
+		"#/panels/1/span: type: is number, expected integer",
+		"#/panels/1: required: missing property 'type'",
+		"#/refresh: pattern: must match '^[0-9]+[smh]$'",
+		"#/tags: uniqueItems: items 0 and 1 are equal",
+		"#/title: minLength: must have at least 1 characters",
+	}, violations(err))
+
+	var ve *schema.ValidationError
+	assert.True(t, errors.As(err, &ve))
+	assert.Equal(t, schema.Violation{
+		Pointer:        "/panels/0/type",
+		Keyword:        "enum",
+		KeywordPointer: "/$defs/panel/properties/type/enum",
+		Message:        `must be one of ["graph","table","text"]`,
+	}, ve.Violations[2])
+	assert.Contains(t, err.Error(), "jsonmap: schema: #/owner: additionalProperties: property 'owner' is not allowed, ")
+
+	assert.Equal(t, []string{
+		"#: type: is array, expected object",
+	}, violations(sch.Validate(jsonmap.FromString(`[]`))))
+	assert.Equal(t, []string{
+		"#: type: is null, expected object",
+	}, violations(sch.Validate(nil)))
+}
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		schema     string
+		valid      []string
+		violations map[string][]string
+	}{
+		{
+			`{ "type": ["string", "null"] }`,
+			[]string{`"a"`, `null`},
+			map[string][]string{`1`: {"#: type: is number, expected string or null"}},
+		},
+		{
+			`{ "type": "integer", "multipleOf": 0.5, "exclusiveMinimum": 0, "exclusiveMaximum": 10 }`,
+			[]string{`1`, `2.0`, `9`},
+			map[string][]string{
+				`0`:   {"#: exclusiveMinimum: must be > 0"},
+				`10`:  {"#: exclusiveMaximum: must be < 10"},
+				`1.5`: {"#: type: is number, expected integer"},
+			},
+		},
+		{
+			`{ "multipleOf": 0.1 }`,
+			[]string{`0.3`, `1`, `"text"`},
+			map[string][]string{`0.35`: {"#: multipleOf: must be a multiple of 0.1"}},
+		},
+		{
+			`{ "const": { "a": [1, 2] } }`,
+			[]string{`{ "a": [1.0, 2] }`},
+			map[string][]string{`{ "a": [2, 1] }`: {`#: const: must be {"a":[1,2]}`}},
+		},
+		{
+			`{ "maxLength": 2 }`,
+			[]string{`"日本"`, `3`},
+			map[string][]string{`"abc"`: {"#: maxLength: must have at most 2 characters"}},
+		},
+		{
+			`{ "prefixItems": [{ "type": "string" }, { "type": "number" }], "items": false, "maxItems": 2 }`,
+			[]string{`[]`, `["a", 1]`},
+			map[string][]string{
+				`[1, "a"]`:    {"#/0: type: is number, expected string", "#/1: type: is string, expected number"},
+				`["a", 1, 2]`: {"#/2: false: no value is allowed", "#: maxItems: must have at most 2 items"},
+			},
+		},
+		{
+			`{ "contains": { "const": "admin" }, "maxContains": 1 }`,
+			[]string{`["admin", "user"]`},
+			map[string][]string{
+				`["user"]`:           {"#: contains: must contain a matching item"},
+				`["admin", "admin"]`: {"#: maxContains: must contain at most 1 matching items"},
+			},
+		},
+		{
+			`{ "contains": { "type": "number" }, "minContains": 2 }`,
+			[]string{`[1, "a", 2]`},
+			map[string][]string{`[1]`: {"#: minContains: must contain at least 2 matching items"}},
+		},
+		{
+			`{
+				"patternProperties": { "^x-": { "type": "string" } },
+				"additionalProperties": { "type": "number" },
+				"propertyNames": { "maxLength": 5 },
+				"minProperties": 1,
+				"maxProperties": 2
+			}`,
+			[]string{`{ "x-a": "a", "b": 1 }`},
+			map[string][]string{
+				`{}`:                         {"#: minProperties: must have at least 1 properties"},
+				`{ "x-a": 1, "b": "b" }`:     {"#/b: type: is string, expected number", "#/x-a: type: is number, expected string"},
+				`{ "a": 1, "b": 2, "c": 3 }`: {"#: maxProperties: must have at most 2 properties"},
+				`{ "x-long": "a" }`:          {"#/x-long: propertyNames: property name 'x-long' is invalid"},
+			},
+		},
+		{
+			`{
+				"dependentRequired": { "user": ["password"] },
+				"dependentSchemas": { "port": { "required": ["host"] } }
+			}`,
+			[]string{`{}`, `{ "user": "a", "password": "b" }`, `{ "host": "h", "port": 1 }`},
+			map[string][]string{
+				`{ "user": "a" }`: {"#: dependentRequired: missing property 'password', required by 'user'"},
+				`{ "port": 1 }`:   {"#: required: missing property 'host'"},
+			},
+		},
+		{
+			`{ "anyOf": [{ "type": "string" }, { "minimum": 0 }], "not": { "const": "none" } }`,
+			[]string{`"a"`, `1`},
+			map[string][]string{
+				`-1`:     {"#: anyOf: must match at least one schema"},
+				`"none"`: {"#: not: must not match the schema"},
+			},
+		},
+		{
+			`{ "oneOf": [{ "type": "integer" }, { "minimum": 2 }] }`,
+			[]string{`1`, `2.5`},
+			map[string][]string{`3`: {"#: oneOf: must match exactly one schema, matched 2"}},
+		},
+		{
+			`{
+				"if": { "properties": { "type": { "const": "graph" } } },
+				"then": { "required": ["query"] },
+				"else": { "not": { "required": ["query"] } },
+				"allOf": [{ "required": ["type"] }]
+			}`,
+			[]string{`{ "type": "graph", "query": "a" }`, `{ "type": "text" }`},
+			map[string][]string{
+				`{ "type": "graph" }`:              {"#: required: missing property 'query'"},
+				`{ "type": "text", "query": "a" }`: {"#: not: must not match the schema"},
+				`{}`:                               {"#: required: missing property 'type'", "#: required: missing property 'query'"},
+			},
+		},
+		{
+			`true`,
+			[]string{`1`, `{}`},
+			nil,
+		},
+		{
+			`false`,
+			nil,
+			map[string][]string{`{}`: {"#: false: no value is allowed"}},
+		},
+	}
+
+	for _, test := range tests {
+		sch := compile(t, test.schema)
+		for _, v := range test.valid {
+			assert.NoError(t, sch.Validate(jsonmap.FromString(v)), "%s with %s", test.schema, v)
+		}
+		for v, expected := range test.violations {
+			assert.Equal(t, expected, violations(sch.Validate(jsonmap.FromString(v))), "%s with %s", test.schema, v)
+		}
+	}
+}
+
+func TestValidateRef(t *testing.T) {
+	// recursive schema
+	sch := compile(t, `{
+		"$defs": {
+			"node": {
+				"$anchor": "node",
+				"type": "object",
+				"properties": {
+					"name": { "type": "string" },
+					"children": { "type": "array", "items": { "$ref": "#node" } }
+				}
+			}
+		},
+		"$ref": "#/$defs/node",
+		"required": ["name"]
+	}`)
+	assert.NoError(t, sch.Validate(jsonmap.FromString(`{ "name": "root", "children": [{ "children": [{ "name": "leaf" }] }] }`)))
+	assert.Equal(t, []string{
+		"#/children/0/children/0/name: type: is number, expected string",
+	}, violations(sch.Validate(jsonmap.FromString(`{ "name": "root", "children": [{ "children": [{ "name": 1 }] }] }`))))
+
+	// root and escaped references, to any location of the document
+	sch = compile(t, `{
+		"properties": {
+			"a~b/c": { "type": "number" },
+			"self": { "$ref": "#" },
+			"alias": { "$ref": "#/properties/a~0b~1c" },
+			"other": { "$ref": "#/x-types/flag" }
+		},
+		"x-types": { "flag": { "type": "boolean" } },
+		"additionalProperties": false
+	}`)
+	assert.NoError(t, sch.Validate(jsonmap.FromString(`{ "self": { "self": { "alias": 1 } }, "other": true }`)))
+	assert.Equal(t, []string{
+		"#/other: type: is number, expected boolean",
+		"#/self/alias: type: is string, expected number",
+		"#/self/self/x: additionalProperties: property 'x' is not allowed",
+	}, violations(sch.Validate(jsonmap.FromString(`{ "self": { "alias": "1", "self": { "x": 1 } }, "other": 1 }`))))
+
+	// cyclic references
+	sch = compile(t, `{ "$defs": { "a": { "$ref": "#/$defs/b" }, "b": { "$ref": "#/$defs/a" } }, "$ref": "#/$defs/a" }`)
+	assert.Equal(t, []string{"#: $ref: maximum depth exceeded"}, violations(sch.Validate(jsonmap.New())))
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := map[string]string{
+		`1`:                                "jsonmap: schema: invalid '#': must be an object or a boolean",
+		`{ "type": "text" }`:               "jsonmap: schema: invalid '#/type': unknown type 'text'",
+		`{ "type": [] }`:                   "jsonmap: schema: invalid '#/type': must be a string or an array of strings",
+		`{ "properties": { "a": 1 } }`:     "jsonmap: schema: invalid '#/properties/a': must be an object or a boolean",
+		`{ "items": { "minLength": -1 } }`: "jsonmap: schema: invalid '#/items/minLength': must be a non-negative integer",
+		`{ "anyOf": [] }`:                  "jsonmap: schema: invalid '#/anyOf': must be a non-empty array",
+		`{ "pattern": "(" }`:               "jsonmap: schema: invalid '#/pattern': invalid pattern: error parsing regexp: missing closing ): `(`",
+		`{ "multipleOf": 0 }`:              "jsonmap: schema: invalid '#/multipleOf': must be strictly greater than 0",
+		`{ "required": [1] }`:              "jsonmap: schema: invalid '#/required': must be an array of strings",
+		`{ "enum": 1 }`:                    "jsonmap: schema: invalid '#/enum': must be an array",
+		`{ "$ref": "#/$defs/missing" }`:    "jsonmap: schema: invalid '#/$ref': reference '#/$defs/missing' not found",
+		`{ "$ref": "#missing" }`:           "jsonmap: schema: invalid '#/$ref': unknown anchor 'missing'",
+		`{ "$ref": "other.json#/a" }`:      "jsonmap: schema: invalid '#/$ref': unsupported reference 'other.json#/a', only references in the document are supported",
+		`{ "$dynamicRef": "#meta" }`:       "jsonmap: schema: invalid '#/$dynamicRef': unsupported keyword",
+		`{ "$ref": "#/properties/a", "properties": { "a": { "$ref": "#/properties/b" } } }`: "jsonmap: schema: invalid '#/properties/a/$ref': reference '#/properties/b' not found",
+	}
+	for s, expected := range tests {
+		_, err := schema.Compile(jsonmap.FromString(s))
+		var se *schema.SchemaError
+		assert.True(t, errors.As(err, &se), s)
+		assert.EqualError(t, err, expected, s)
+	}
+
+	_, err := schema.Compile(nil)
+	assert.EqualError(t, err, "jsonmap: schema: invalid '#': must be an object or a boolean")
+	assert.Panics(t, func() { schema.MustCompile(jsonmap.FromString(`{ "type": 1 }`)) })
+}
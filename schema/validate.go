@@ -0,0 +1,307 @@
+package schema
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/datasweet/jsonmap"
+)
+
+// maxDepth limits the nesting of validated schemas, to stop cyclic references
+const maxDepth = 1000
+
+// validator collects the violations of a validation
+type validator struct {
+	violations []Violation
+}
+
+// fail adds a violation of a keyword of n
+func (v *validator) fail(n *node, keyword string, pointer string, format string, args ...interface{}) {
+	v.violations = append(v.violations, Violation{
+		Pointer:        pointer,
+		Keyword:        keyword,
+		KeywordPointer: childPointer(n.pointer, keyword),
+		Message:        fmt.Sprintf(format, args...),
+	})
+}
+
+// valid checks if j is valid against n, without collecting violations
+func valid(n *node, j *jsonmap.Json, pointer string, depth int) bool {
+	sub := &validator{}
+	sub.validate(n, j, pointer, depth)
+	return len(sub.violations) == 0
+}
+
+// validate validates j located at pointer in the document against n
+func (v *validator) validate(n *node, j *jsonmap.Json, pointer string, depth int) {
+	if n.always != nil {
+		if !*n.always {
+			v.violations = append(v.violations, Violation{
+				Pointer:        pointer,
+				Keyword:        "false",
+				KeywordPointer: n.pointer,
+				Message:        "no value is allowed",
+			})
+		}
+		return
+	}
+	if depth > maxDepth {
+		v.fail(n, "$ref", pointer, "maximum depth exceeded")
+		return
+	}
+
+	if n.refNode != nil {
+		v.validate(n.refNode, j, pointer, depth+1)
+	}
+	v.validateValue(n, j, pointer)
+	v.validateApplicators(n, j, pointer, depth)
+
+	switch j.Kind() {
+	case jsonmap.NumberKind:
+		v.validateNumber(n, j, pointer)
+	case jsonmap.StringKind:
+		v.validateString(n, j, pointer)
+	case jsonmap.ArrayKind:
+		v.validateArray(n, j, pointer, depth)
+	case jsonmap.ObjectKind:
+		v.validateObject(n, j, pointer, depth)
+	}
+}
+
+// validateValue validates the type, enum and const keywords
+func (v *validator) validateValue(n *node, j *jsonmap.Json, pointer string) {
+	if len(n.types) > 0 {
+		kind := j.Kind().String()
+		matched := false
+		for _, t := range n.types {
+			if t == kind || (t == "integer" && isInteger(j)) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(n, "type", pointer, "is %s, expected %s", kind, strings.Join(n.types, " or "))
+		}
+	}
+
+	if n.enum != nil {
+		found := false
+		for _, e := range n.enum {
+			if jsonmap.Equal(j, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values := make([]string, len(n.enum))
+			for i, e := range n.enum {
+				values[i] = e.Stringify()
+			}
+			v.fail(n, "enum", pointer, "must be one of [%s]", strings.Join(values, ","))
+		}
+	}
+
+	if n.constant != nil && !jsonmap.Equal(j, n.constant) {
+		v.fail(n, "const", pointer, "must be %s", n.constant.Stringify())
+	}
+}
+
+// validateApplicators validates the in-place applicators : allOf, anyOf, oneOf, not, if, then and else
+func (v *validator) validateApplicators(n *node, j *jsonmap.Json, pointer string, depth int) {
+	for _, s := range n.allOf {
+		v.validate(s, j, pointer, depth+1)
+	}
+
+	if n.anyOf != nil {
+		matched := false
+		for _, s := range n.anyOf {
+			if valid(s, j, pointer, depth+1) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(n, "anyOf", pointer, "must match at least one schema")
+		}
+	}
+
+	if n.oneOf != nil {
+		matched := 0
+		for _, s := range n.oneOf {
+			if valid(s, j, pointer, depth+1) {
+				matched++
+			}
+		}
+		if matched != 1 {
+			v.fail(n, "oneOf", pointer, "must match exactly one schema, matched %d", matched)
+		}
+	}
+
+	if n.not != nil && valid(n.not, j, pointer, depth+1) {
+		v.fail(n, "not", pointer, "must not match the schema")
+	}
+
+	if n.ifNode != nil {
+		if valid(n.ifNode, j, pointer, depth+1) {
+			if n.thenNode != nil {
+				v.validate(n.thenNode, j, pointer, depth+1)
+			}
+		} else if n.elseNode != nil {
+			v.validate(n.elseNode, j, pointer, depth+1)
+		}
+	}
+}
+
+// validateNumber validates the numeric keywords
+func (v *validator) validateNumber(n *node, j *jsonmap.Json, pointer string) {
+	num, ok := numberOf(j)
+	if !ok {
+		return
+	}
+	if n.multipleOf != nil {
+		q := new(big.Rat).Quo(num, n.multipleOf.rat)
+		if !q.IsInt() {
+			v.fail(n, "multipleOf", pointer, "must be a multiple of %s", n.multipleOf.text)
+		}
+	}
+	if n.maximum != nil && num.Cmp(n.maximum.rat) > 0 {
+		v.fail(n, "maximum", pointer, "must be <= %s", n.maximum.text)
+	}
+	if n.exclusiveMaximum != nil && num.Cmp(n.exclusiveMaximum.rat) >= 0 {
+		v.fail(n, "exclusiveMaximum", pointer, "must be < %s", n.exclusiveMaximum.text)
+	}
+	if n.minimum != nil && num.Cmp(n.minimum.rat) < 0 {
+		v.fail(n, "minimum", pointer, "must be >= %s", n.minimum.text)
+	}
+	if n.exclusiveMinimum != nil && num.Cmp(n.exclusiveMinimum.rat) <= 0 {
+		v.fail(n, "exclusiveMinimum", pointer, "must be > %s", n.exclusiveMinimum.text)
+	}
+}
+
+// validateString validates the string keywords, lengths are in characters
+func (v *validator) validateString(n *node, j *jsonmap.Json, pointer string) {
+	s, _ := j.String()
+	length := utf8.RuneCountInString(s)
+	if n.maxLength >= 0 && length > n.maxLength {
+		v.fail(n, "maxLength", pointer, "must have at most %d characters", n.maxLength)
+	}
+	if length < n.minLength {
+		v.fail(n, "minLength", pointer, "must have at least %d characters", n.minLength)
+	}
+	if n.pattern != nil && !n.pattern.MatchString(s) {
+		v.fail(n, "pattern", pointer, "must match '%s'", n.pattern)
+	}
+}
+
+// validateArray validates the array keywords
+func (v *validator) validateArray(n *node, j *jsonmap.Json, pointer string, depth int) {
+	items := j.Values()
+	for i, item := range items {
+		p := childPointer(pointer, strconv.Itoa(i))
+		if i < len(n.prefixItems) {
+			v.validate(n.prefixItems[i], item, p, depth+1)
+		} else if n.items != nil {
+			v.validate(n.items, item, p, depth+1)
+		}
+	}
+
+	if n.contains != nil {
+		matched := 0
+		for i, item := range items {
+			if valid(n.contains, item, childPointer(pointer, strconv.Itoa(i)), depth+1) {
+				matched++
+			}
+		}
+		if matched < n.minContains {
+			if n.minContains == 1 {
+				v.fail(n, "contains", pointer, "must contain a matching item")
+			} else {
+				v.fail(n, "minContains", pointer, "must contain at least %d matching items", n.minContains)
+			}
+		}
+		if n.maxContains >= 0 && matched > n.maxContains {
+			v.fail(n, "maxContains", pointer, "must contain at most %d matching items", n.maxContains)
+		}
+	}
+
+	if n.maxItems >= 0 && len(items) > n.maxItems {
+		v.fail(n, "maxItems", pointer, "must have at most %d items", n.maxItems)
+	}
+	if len(items) < n.minItems {
+		v.fail(n, "minItems", pointer, "must have at least %d items", n.minItems)
+	}
+	if n.uniqueItems {
+	unique:
+		for i := range items {
+			for k := i + 1; k < len(items); k++ {
+				if jsonmap.Equal(items[i], items[k]) {
+					v.fail(n, "uniqueItems", pointer, "items %d and %d are equal", i, k)
+					break unique
+				}
+			}
+		}
+	}
+}
+
+// validateObject validates the object keywords, members are validated in key order
+func (v *validator) validateObject(n *node, j *jsonmap.Json, pointer string, depth int) {
+	m := members(j)
+	keys := j.SortedKeys()
+
+	for _, k := range keys {
+		p := childPointer(pointer, k)
+		evaluated := false
+		if s, ok := n.properties[k]; ok {
+			v.validate(s, m[k], p, depth+1)
+			evaluated = true
+		}
+		for _, pp := range n.patternProperties {
+			if pp.re.MatchString(k) {
+				v.validate(pp.node, m[k], p, depth+1)
+				evaluated = true
+			}
+		}
+		if !evaluated && n.additionalProperties != nil {
+			if n.additionalProperties.always != nil && !*n.additionalProperties.always {
+				v.fail(n, "additionalProperties", p, "property '%s' is not allowed", k)
+			} else {
+				v.validate(n.additionalProperties, m[k], p, depth+1)
+			}
+		}
+		if n.propertyNames != nil {
+			name, _ := jsonmap.FromValue(k)
+			if !valid(n.propertyNames, name, p, depth+1) {
+				v.fail(n, "propertyNames", p, "property name '%s' is invalid", k)
+			}
+		}
+		if s, ok := n.dependentSchemas[k]; ok {
+			v.validate(s, j, pointer, depth+1)
+		}
+		for _, dep := range n.dependentRequired[k] {
+			if _, ok := m[dep]; !ok {
+				v.fail(n, "dependentRequired", pointer, "missing property '%s', required by '%s'", dep, k)
+			}
+		}
+	}
+
+	for _, k := range n.required {
+		if _, ok := m[k]; !ok {
+			v.fail(n, "required", pointer, "missing property '%s'", k)
+		}
+	}
+	if n.maxProperties >= 0 && len(keys) > n.maxProperties {
+		v.fail(n, "maxProperties", pointer, "must have at most %d properties", n.maxProperties)
+	}
+	if len(keys) < n.minProperties {
+		v.fail(n, "minProperties", pointer, "must have at least %d properties", n.minProperties)
+	}
+}
+
+// isInteger checks if j is a number without fractional part, ie 1 or 1.0
+func isInteger(j *jsonmap.Json) bool {
+	num, ok := numberOf(j)
+	return ok && num.IsInt()
+}
@@ -0,0 +1,288 @@
+package jsonmap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// defaultMaxRecordSize is the default max size of a Decoder record
+const defaultMaxRecordSize = 64 << 20
+
+// DecoderOptions are our Decoder options
+type DecoderOptions struct {
+	MaxRecordSize int
+	Parse         ParseOptions
+}
+
+// DecoderOption is a decoder option setter
+type DecoderOption func(o *DecoderOptions)
+
+func newDecoderOptions(opt ...DecoderOption) DecoderOptions {
+	opts := DecoderOptions{
+		MaxRecordSize: defaultMaxRecordSize,
+		Parse:         defaultParseOptions,
+	}
+	for _, o := range opt {
+		o(&opts)
+	}
+	return opts
+}
+
+// MaxRecordSize sets the max size in bytes of a record, 0 for no limit
+// default : 64 MiB
+func MaxRecordSize(size int) DecoderOption {
+	return func(opts *DecoderOptions) {
+		if size >= 0 {
+			opts.MaxRecordSize = size
+		}
+	}
+}
+
+// ParseWith sets the options used to parse the records,
+// on top of the package-level ones, see SetDefaultParseOptions
+func ParseWith(opt ...ParseOption) DecoderOption {
+	return func(opts *DecoderOptions) {
+		for _, o := range opt {
+			o(&opts.Parse)
+		}
+	}
+}
+
+// RecordError is returned by a Decoder when a record exceeds the max size, see MaxRecordSize
+type RecordError struct {
+	Line int // line of the start of the record
+	Size int // max size of a record
+}
+
+// Error implements the error interface
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("jsonmap: record at line %d exceeds the max size of %d bytes", e.Line, e.Size)
+}
+
+// Decoder reads a stream of jsons : newline-delimited jsons (NDJSON) or concatenated jsons,
+// ie an Elasticsearch _bulk payload or a scroll export
+type Decoder struct {
+	r    *bufio.Reader
+	opts DecoderOptions
+	buf  []byte
+
+	// position of the next byte
+	offset int64
+	line   int
+	column int
+
+	recordLine int // line of the last record
+}
+
+// NewDecoder creates a decoder reading from r
+// Example : NewDecoder(r, MaxRecordSize(1 << 20), ParseWith(UseNumber(true)))
+func NewDecoder(r io.Reader, opt ...DecoderOption) *Decoder {
+	return &Decoder{
+		r:      bufio.NewReader(r),
+		opts:   newDecoderOptions(opt...),
+		line:   1,
+		column: 1,
+	}
+}
+
+// Line returns the line of the start of the last record, starting at 1
+func (d *Decoder) Line() int {
+	return d.recordLine
+}
+
+// Decode reads the next json of the stream
+// Returns io.EOF at the end of the stream, a *SyntaxError positioned in the stream if the record is not a valid json,
+// or a *RecordError if the record is too large. The decoder can continue after a *SyntaxError or a *RecordError
+func (d *Decoder) Decode() (*Json, error) {
+	c, err := d.skipSpaces()
+	if err != nil {
+		return nil, err
+	}
+	return d.record(c)
+}
+
+// record reads and decodes a value starting with c, errors are positioned in the stream
+func (d *Decoder) record(c byte) (*Json, error) {
+	startOffset, startLine, startColumn := d.offset-1, d.line, d.column-1
+	d.recordLine = startLine
+	d.buf = d.buf[:0]
+	tooLarge := false
+	add := func(c byte) {
+		if d.opts.MaxRecordSize > 0 && len(d.buf) >= d.opts.MaxRecordSize {
+			tooLarge = true
+			return
+		}
+		d.buf = append(d.buf, c)
+	}
+	add(c)
+
+	if err := d.scanValue(c, add); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if tooLarge {
+		return nil, &RecordError{Line: startLine, Size: d.opts.MaxRecordSize}
+	}
+
+	v, err := decode(d.buf, d.opts.Parse)
+	if err != nil {
+		var se *SyntaxError
+		if errors.As(err, &se) {
+			if se.Line == 1 {
+				se.Column += startColumn - 1
+			}
+			se.Line += startLine - 1
+			se.Offset += startOffset
+		}
+		return nil, err
+	}
+	return &Json{data: v}, nil
+}
+
+// readByte reads the next byte, keeping track of its position
+func (d *Decoder) readByte() (byte, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	d.offset++
+	if c == '\n' {
+		d.line++
+		d.column = 1
+	} else {
+		d.column++
+	}
+	return c, nil
+}
+
+// unreadByte unreads the last byte, which is not a newline
+func (d *Decoder) unreadByte() {
+	d.r.UnreadByte()
+	d.offset--
+	d.column--
+}
+
+// skipSpaces returns the first byte which is not a whitespace
+func (d *Decoder) skipSpaces() (byte, error) {
+	for {
+		c, err := d.readByte()
+		if err != nil {
+			return 0, err
+		}
+		if !isSpace(c) {
+			return c, nil
+		}
+	}
+}
+
+// scanValue reads the rest of a value starting with c, without validating it
+// Objects and arrays end with their closing delimiter, scalars before the next whitespace or delimiter
+func (d *Decoder) scanValue(c byte, add func(c byte)) error {
+	switch c {
+	case '{', '[':
+		depth := 1
+		for depth > 0 {
+			c, err := d.readByte()
+			if err != nil {
+				return err
+			}
+			add(c)
+			switch c {
+			case '{', '[':
+				depth++
+			case '}', ']':
+				depth--
+			case '"':
+				if err := d.scanString(add); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case '"':
+		return d.scanString(add)
+
+	case '}', ']', ',', ':':
+		return nil
+
+	default:
+		for {
+			c, err := d.readByte()
+			if err != nil {
+				return err
+			}
+			if isSpace(c) || isDelimiter(c) {
+				if c != '\n' {
+					d.unreadByte()
+				}
+				return nil
+			}
+			add(c)
+		}
+	}
+}
+
+// scanString reads the rest of a string, until its closing quote
+func (d *Decoder) scanString(add func(c byte)) error {
+	escaped := false
+	for {
+		c, err := d.readByte()
+		if err != nil {
+			return err
+		}
+		add(c)
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"':
+			return nil
+		}
+	}
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+func isDelimiter(c byte) bool {
+	switch c {
+	case '{', '}', '[', ']', ',', ':', '"':
+		return true
+	}
+	return false
+}
+
+// Encoder writes jsons as newline-delimited jsons (NDJSON)
+type Encoder struct {
+	w    io.Writer
+	opts StringifyOptions
+}
+
+// NewEncoder creates an encoder writing to w
+// Example : NewEncoder(w, Canonical(true))
+func NewEncoder(w io.Writer, opt ...StringifyOption) *Encoder {
+	return &Encoder{w: w, opts: newStringifyOptions(opt...)}
+}
+
+// Encode writes a json followed by a newline, a nil json is written as null
+func (e *Encoder) Encode(j *Json) error {
+	if j == nil {
+		j = Nil()
+	}
+	var data []byte
+	var err error
+	if e.opts.Canonical {
+		data, err = canonicalize(dataOf(j))
+	} else {
+		data, err = j.MarshalJSON()
+	}
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(append(data, '\n'))
+	return err
+}
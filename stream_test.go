@@ -0,0 +1,148 @@
+package jsonmap_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeAll(dec *jsonmap.Decoder) ([]string, []error) {
+	var records []string
+	var errs []error
+	for {
+		j, err := dec.Decode()
+		if err == io.EOF {
+			return records, errs
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		records = append(records, j.Stringify())
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	bulk := `{ "index": { "_index": "logs", "_id": "1" } }
+{ "message": "hello\nworld", "braces": "}{][" }
+
+{ "delete": { "_index": "logs", "_id": "2" } }
+`
+	dec := jsonmap.NewDecoder(strings.NewReader(bulk))
+	j, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, dec.Line())
+	assert.Equal(t, "logs", j.Get("index._index").AsString())
+
+	j, err = dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, dec.Line())
+	assert.Equal(t, "hello\nworld", j.Get("message").AsString())
+
+	j, err = dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, dec.Line())
+	assert.True(t, j.Has("delete"))
+
+	_, err = dec.Decode()
+	assert.Equal(t, io.EOF, err)
+
+	// concatenated jsons, without newlines
+	records, errs := decodeAll(jsonmap.NewDecoder(strings.NewReader(`{"a":1}[2,3]"four" 5 true null{
+		"b": [
+			6
+		]
+	}-7.5e1`)))
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{`{"a":1}`, `[2,3]`, `"four"`, `5`, `true`, `null`, `{"b":[6]}`, `-75`}, records)
+
+	records, errs = decodeAll(jsonmap.NewDecoder(strings.NewReader("")))
+	assert.Empty(t, records)
+	assert.Empty(t, errs)
+}
+
+func TestDecoderOptions(t *testing.T) {
+	dec := jsonmap.NewDecoder(strings.NewReader(`{ "b": 12345678901234567890, "a": 1 }`), jsonmap.ParseWith(jsonmap.UseNumber(true), jsonmap.Ordered(true)))
+	j, err := dec.Decode()
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("12345678901234567890"), j.Get("b").Data())
+	assert.Equal(t, []string{"b", "a"}, j.Keys())
+
+	input := `{ "a": 1 }
+{ "message": "a too long message" }
+{ "b": 2 }
+`
+	dec = jsonmap.NewDecoder(strings.NewReader(input), jsonmap.MaxRecordSize(16))
+	records, errs := decodeAll(dec)
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`}, records)
+	assert.Len(t, errs, 1)
+	var re *jsonmap.RecordError
+	assert.True(t, errors.As(errs[0], &re))
+	assert.Equal(t, 2, re.Line)
+	assert.EqualError(t, errs[0], "jsonmap: record at line 2 exceeds the max size of 16 bytes")
+
+	records, errs = decodeAll(jsonmap.NewDecoder(strings.NewReader(input), jsonmap.MaxRecordSize(0)))
+	assert.Len(t, records, 3)
+	assert.Empty(t, errs)
+}
+
+func TestDecoderErrors(t *testing.T) {
+	input := `{ "a": 1 }
+  { "b": x }
+{ "c": 3 }
+] { "d": [1, } }
+{ "e": `
+	records, errs := decodeAll(jsonmap.NewDecoder(strings.NewReader(input)))
+	assert.Equal(t, []string{`{"a":1}`, `{"c":3}`}, records)
+	if !assert.Len(t, errs, 4) {
+		return
+	}
+
+	var se *jsonmap.SyntaxError
+	assert.True(t, errors.As(errs[0], &se))
+	assert.Equal(t, 2, se.Line)
+	assert.Equal(t, 10, se.Column)
+	assert.Equal(t, int64(20), se.Offset)
+	assert.Equal(t, byte('x'), input[se.Offset])
+
+	assert.True(t, errors.As(errs[1], &se))
+	assert.Equal(t, 4, se.Line)
+	assert.Equal(t, 1, se.Column)
+
+	assert.True(t, errors.As(errs[2], &se))
+	assert.Equal(t, 4, se.Line)
+	assert.Equal(t, 14, se.Column)
+	assert.Equal(t, byte('}'), input[se.Offset])
+
+	assert.True(t, errors.As(errs[3], &se))
+	assert.Equal(t, 5, se.Line)
+	assert.Contains(t, se.Error(), "unexpected end of JSON input at line 5")
+}
+
+func TestEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := jsonmap.NewEncoder(&buf)
+	assert.NoError(t, enc.Encode(jsonmap.FromString(`{ "index": { "_id": "1" } }`)))
+	assert.NoError(t, enc.Encode(jsonmap.FromString(`{ "message": "a\nb" }`)))
+	assert.NoError(t, enc.Encode(nil))
+	assert.Equal(t, "{\"index\":{\"_id\":\"1\"}}\n{\"message\":\"a\\nb\"}\nnull\n", buf.String())
+
+	records, errs := decodeAll(jsonmap.NewDecoder(&buf))
+	assert.Empty(t, errs)
+	assert.Equal(t, []string{`{"index":{"_id":"1"}}`, `{"message":"a\nb"}`, `null`}, records)
+
+	buf.Reset()
+	enc = jsonmap.NewEncoder(&buf, jsonmap.Canonical(true))
+	assert.NoError(t, enc.Encode(jsonmap.FromString(`{ "b": 1.0, "a": "<>" }`)))
+	assert.Equal(t, "{\"a\":\"<>\",\"b\":1}\n", buf.String())
+
+	j := jsonmap.New()
+	j.Set("ch", make(chan int))
+	assert.Error(t, enc.Encode(j))
+}
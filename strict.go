@@ -0,0 +1,198 @@
+package jsonmap
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// Kind is the json kind of a value
+type Kind uint8
+
+// Json kinds
+const (
+	NullKind Kind = iota
+	BoolKind
+	NumberKind
+	StringKind
+	ArrayKind
+	ObjectKind
+	UnknownKind // a go value which is not a json value, ie a struct set with Set
+)
+
+var kindNames = map[Kind]string{
+	NullKind:    "null",
+	BoolKind:    "boolean",
+	NumberKind:  "number",
+	StringKind:  "string",
+	ArrayKind:   "array",
+	ObjectKind:  "object",
+	UnknownKind: "unknown",
+}
+
+// String implements the fmt.Stringer interface
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return kindNames[UnknownKind]
+}
+
+// kindOf returns the json kind of an uncasted value
+func kindOf(v interface{}) Kind {
+	switch v.(type) {
+	case nil:
+		return NullKind
+	case bool:
+		return BoolKind
+	case json.Number, float32, float64, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return NumberKind
+	case string:
+		return StringKind
+	case []interface{}:
+		return ArrayKind
+	case map[string]interface{}, *orderedObject:
+		return ObjectKind
+	default:
+		return UnknownKind
+	}
+}
+
+// Kind returns the json kind of the current Json
+func (j *Json) Kind() Kind {
+	j.load()
+	return kindOf(j.data)
+}
+
+// TypeError is returned by strict accessors when the value
+// can't be represented by the requested type
+type TypeError struct {
+	Path     string // path of the value, empty for a root json
+	Expected string // requested type, ie "string", "int64"
+	Actual   Kind   // json kind of the value
+}
+
+// Error implements the error interface
+func (e *TypeError) Error() string {
+	if len(e.Path) == 0 {
+		return fmt.Sprintf("jsonmap: value is %s, expected %s", e.Actual, e.Expected)
+	}
+	return fmt.Sprintf("jsonmap: value at '%s' is %s, expected %s", e.Path, e.Actual, e.Expected)
+}
+
+func (j *Json) typeError(expected string) error {
+	return &TypeError{
+		Path:     j.path,
+		Expected: expected,
+		Actual:   j.Kind(),
+	}
+}
+
+// String casts underlying to string
+// Returns a *TypeError if not a string
+func (j *Json) String() (string, error) {
+	if casted, ok := j.asString(); ok {
+		return casted, nil
+	}
+	return "", j.typeError("string")
+}
+
+// Bool casts underlying to boolean
+// Returns a *TypeError if not a boolean
+func (j *Json) Bool() (bool, error) {
+	if casted, ok := j.asBool(); ok {
+		return casted, nil
+	}
+	return false, j.typeError("boolean")
+}
+
+// Int64 casts underlying to int64
+// Returns a *TypeError if not a number or if the number is not an int64
+func (j *Json) Int64() (int64, error) {
+	switch v := j.as(NumberKind).(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), nil
+		}
+	case float32, float64:
+		if f := reflect.ValueOf(v).Float(); f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+			return int64(f), nil
+		}
+	case int, int8, int16, int32, int64:
+		return reflect.ValueOf(v).Int(), nil
+	case uint, uint8, uint16, uint32, uint64:
+		if u := reflect.ValueOf(v).Uint(); u <= math.MaxInt64 {
+			return int64(u), nil
+		}
+	}
+	return 0, j.typeError("int64")
+}
+
+// Uint64 casts underlying to uint64
+// Returns a *TypeError if not a number or if the number is not an uint64
+func (j *Json) Uint64() (uint64, error) {
+	switch v := j.as(NumberKind).(type) {
+	case json.Number:
+		if u, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return u, nil
+		}
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 {
+			return uint64(f), nil
+		}
+	case float32, float64:
+		if f := reflect.ValueOf(v).Float(); f == math.Trunc(f) && f >= 0 && f < math.MaxUint64 {
+			return uint64(f), nil
+		}
+	case int, int8, int16, int32, int64:
+		if i := reflect.ValueOf(v).Int(); i >= 0 {
+			return uint64(i), nil
+		}
+	case uint, uint8, uint16, uint32, uint64:
+		return reflect.ValueOf(v).Uint(), nil
+	}
+	return 0, j.typeError("uint64")
+}
+
+// Float64 casts underlying to float64
+// Returns a *TypeError if not a number
+func (j *Json) Float64() (float64, error) {
+	if f, ok := j.asFloat(); ok {
+		return f, nil
+	}
+	return 0, j.typeError("float64")
+}
+
+// Time casts underlying to time.Time, see AsTime
+// Returns a *TypeError if not a time
+func (j *Json) Time() (time.Time, error) {
+	if t, ok := j.asTime(); ok {
+		return t, nil
+	}
+	return time.Time{}, j.typeError("time")
+}
+
+// Object casts underlying to object (map[string]interface{})
+// Returns a *TypeError if not an object
+func (j *Json) Object() (map[string]interface{}, error) {
+	j.load()
+	if casted, ok := objectOf(j.data); ok {
+		return casted, nil
+	}
+	return nil, j.typeError("object")
+}
+
+// Array casts underlying to array ([]interface{})
+// Returns a *TypeError if not an array
+func (j *Json) Array() ([]interface{}, error) {
+	j.load()
+	if casted, ok := (j.data).([]interface{}); ok {
+		return casted, nil
+	}
+	return nil, j.typeError("array")
+}
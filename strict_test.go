@@ -0,0 +1,135 @@
+package jsonmap_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/datasweet/jsonmap"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKind(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+	assert.Equal(t, jsonmap.ObjectKind, j.Kind())
+	assert.Equal(t, jsonmap.StringKind, j.Get("string").Kind())
+	assert.Equal(t, jsonmap.BoolKind, j.Get("bool").Kind())
+	assert.Equal(t, jsonmap.NumberKind, j.Get("number").Kind())
+	assert.Equal(t, jsonmap.ArrayKind, j.Get("array").Kind())
+	assert.Equal(t, jsonmap.NullKind, j.Get("unknown").Kind())
+	assert.Equal(t, "object", jsonmap.ObjectKind.String())
+}
+
+func TestPath(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+	assert.Equal(t, "", j.Path())
+	assert.Equal(t, "object.sub[1].a", j.Get("object.sub[1].a").Path())
+	assert.Equal(t, "object.sub[1].a", j.Get("object").Get("sub").Get("[1]").Get("a").Path())
+	assert.Equal(t, "object.unknown", j.Get("object").Get("unknown").Path())
+
+	var paths []string
+	for _, v := range j.Get("object.sub").Values() {
+		paths = append(paths, v.Path())
+	}
+	assert.Equal(t, []string{"object.sub[0]", "object.sub[1]"}, paths)
+
+	j = jsonmap.FromString(`{ "message.raw": "hello" }`)
+	j.ForEach(func(k string, v *jsonmap.Json) bool {
+		assert.Equal(t, `message\.raw`, v.Path())
+		assert.Equal(t, "hello", j.Get(v.Path()).AsString())
+		return true
+	})
+}
+
+func TestStrictString(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+	s, err := j.Get("string").String()
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", s)
+
+	_, err = j.Get("object.sub[0].a").String()
+	var te *jsonmap.TypeError
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, "object.sub[0].a", te.Path)
+	assert.Equal(t, "string", te.Expected)
+	assert.Equal(t, jsonmap.NumberKind, te.Actual)
+	assert.Equal(t, "jsonmap: value at 'object.sub[0].a' is number, expected string", err.Error())
+
+	_, err = j.Get("unknown").String()
+	assert.True(t, errors.As(err, &te))
+	assert.Equal(t, jsonmap.NullKind, te.Actual)
+
+	_, err = jsonmap.FromString("12").String()
+	assert.Equal(t, "jsonmap: value is number, expected string", err.Error())
+}
+
+func TestStrictBool(t *testing.T) {
+	j := jsonmap.FromString(`{ "ok": true, "str": "true" }`)
+	b, err := j.Get("ok").Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	_, err = j.Get("str").Bool()
+	assert.EqualError(t, err, "jsonmap: value at 'str' is string, expected boolean")
+}
+
+func TestStrictNumbers(t *testing.T) {
+	j := jsonmap.FromString(`{ "int": 12, "neg": -3, "float": 1.5, "str": "12", "big": 1e30 }`)
+
+	i, err := j.Get("int").Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), i)
+
+	_, err = j.Get("float").Int64()
+	assert.EqualError(t, err, "jsonmap: value at 'float' is number, expected int64")
+
+	_, err = j.Get("big").Int64()
+	assert.Error(t, err)
+
+	_, err = j.Get("str").Int64()
+	assert.EqualError(t, err, "jsonmap: value at 'str' is string, expected int64")
+
+	u, err := j.Get("int").Uint64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(12), u)
+
+	_, err = j.Get("neg").Uint64()
+	assert.Error(t, err)
+
+	f, err := j.Get("float").Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, 1.5, f)
+
+	_, err = j.Get("str").Float64()
+	assert.Error(t, err)
+
+	n, err := jsonmap.ParseString(`{ "id": 9007199254740993 }`, jsonmap.UseNumber(true))
+	assert.NoError(t, err)
+	i, err = n.Get("id").Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(9007199254740993), i)
+
+	s := jsonmap.New()
+	s.Set("n", json.Number("1e2"))
+	i, err = s.Get("n").Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), i)
+}
+
+func TestStrictContainers(t *testing.T) {
+	j := jsonmap.FromString(jsonTest)
+
+	o, err := j.Get("object").Object()
+	assert.NoError(t, err)
+	assert.Len(t, o, 2)
+
+	_, err = j.Get("array").Object()
+	assert.EqualError(t, err, "jsonmap: value at 'array' is array, expected object")
+
+	a, err := j.Get("array").Array()
+	assert.NoError(t, err)
+	assert.Len(t, a, 5)
+
+	_, err = j.Get("object").Array()
+	assert.EqualError(t, err, "jsonmap: value at 'object' is object, expected array")
+}
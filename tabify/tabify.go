@@ -23,7 +23,7 @@ func Tabify(j *jsonmap.Json, writer TableWriter, opt ...Option) error {
 
 // JSON to flatten a json
 func JSON(j *jsonmap.Json, opt ...Option) ([]*jsonmap.Json, error) {
-	writer := &jsonTableWriter{}
+	writer := &jsonTableWriter{ordered: j.IsOrdered()}
 	if err := Tabify(j, writer, opt...); err != nil {
 		return nil, err
 	}
@@ -33,7 +33,7 @@ func JSON(j *jsonmap.Json, opt ...Option) ([]*jsonmap.Json, error) {
 // Slice to tabify into a slice array.
 // Note : first row contains headers
 func Slice(j *jsonmap.Json, opt ...Option) ([][]interface{}, error) {
-	writer := &sliceTableWriter{}
+	writer := &sliceTableWriter{ordered: j.IsOrdered()}
 	if err := Tabify(j, writer, opt...); err != nil {
 		return nil, err
 	}
@@ -99,3 +99,18 @@ func readJSON(t *testing.T, filename string) string {
 	}
 	return string(data[:])
 }
+
+func TestUseNumber(t *testing.T) {
+	src, err := jsonmap.ParseString(`{ "buckets": [{ "key": 9007199254740993, "doc_count": 2 }] }`, jsonmap.UseNumber(true))
+	assert.NoError(t, err)
+
+	mt, err := tabify.Map(src)
+	assert.NoError(t, err)
+	assert.Len(t, mt, 1)
+	assert.Equal(t, json.Number("9007199254740993"), mt[0]["buckets#key"])
+
+	jt, err := tabify.JSON(src)
+	assert.NoError(t, err)
+	assert.Len(t, jt, 1)
+	assert.Equal(t, `{"buckets#doc_count":2,"buckets#key":9007199254740993}`, jt[0].Stringify())
+}
@@ -15,11 +15,16 @@ type TableWriter interface {
 
 // jsonTableWriter to write a json array
 type jsonTableWriter struct {
-	row   *jsonmap.Json
-	table []*jsonmap.Json
+	row     *jsonmap.Json
+	table   []*jsonmap.Json
+	ordered bool // rows keep the order of the columns
 }
 
 func (w *jsonTableWriter) OpenRow() {
+	if w.ordered {
+		w.row = jsonmap.NewOrdered()
+		return
+	}
 	w.row = jsonmap.New()
 }
 
@@ -62,10 +67,11 @@ func (w *mapTableWriter) Table() []map[string]interface{} {
 
 // sliceTableWriter to writes into a slice
 type sliceTableWriter struct {
-	cols  []*sliceCol
-	row   map[string]interface{}
-	table [][]interface{}
-	len   int
+	cols    []*sliceCol
+	row     map[string]interface{}
+	table   [][]interface{}
+	len     int
+	ordered bool // keeps the order of the columns in the json instead of sorting them by name
 }
 
 type sliceCol struct {
@@ -87,8 +93,8 @@ func (w *sliceTableWriter) Cell(k string, v interface{}, deep int) {
 func (w *sliceTableWriter) CloseRow() {
 	if w.len == 0 {
 		// compute cols
-		sort.Slice(w.cols, func(i, j int) bool {
-			if w.cols[i].deep == w.cols[j].deep {
+		sort.SliceStable(w.cols, func(i, j int) bool {
+			if w.cols[i].deep == w.cols[j].deep && !w.ordered {
 				return w.cols[i].name < w.cols[j].name
 			}
 			return w.cols[i].deep < w.cols[j].deep
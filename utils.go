@@ -1,9 +1,66 @@
 package jsonmap
 
-import "strings"
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
 
-func createPath(path string) []string {
-	var keys []string
+// pathKey is a parsed key of a path
+type pathKey struct {
+	name     string // object key
+	index    int    // array index, negative indexes start from the end
+	isIndex  bool   // name is an array index
+	wildcard bool   // matches all the children, ie [*]
+	append   bool   // appends to an array, ie tags[]
+	bracket  bool   // written between brackets, ie [0]
+}
+
+// newPathKey parses a key of the dotted syntax
+// A "*" name is a wildcard, and "\*" the key "*"
+func newPathKey(name string, bracket bool) pathKey {
+	switch name {
+	case "*":
+		return pathKey{name: name, wildcard: true, bracket: bracket}
+	case "\\*":
+		return pathKey{name: "*", bracket: bracket}
+	}
+	idx, err := strconv.Atoi(name)
+	return pathKey{name: name, index: idx, isIndex: err == nil, bracket: bracket}
+}
+
+// indexOf parses an array index written canonically : digits without sign nor leading zeros,
+// or a minus followed by such digits, ie "0", "12" or "-1" but not "+1", "01" or "-0"
+// ParsePath and JSON Pointers use it, the lenient paths of Get and Set accept any integer
+func indexOf(name string) (int, bool) {
+	digits := strings.TrimPrefix(name, "-")
+	if len(digits) == 0 || (digits[0] == '0' && (len(digits) > 1 || len(name) > 1)) {
+		return 0, false
+	}
+	for _, c := range digits {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+	}
+	idx, err := strconv.Atoi(name)
+	return idx, err == nil
+}
+
+// hasWildcard checks if one of the keys is a wildcard
+func hasWildcard(keys []pathKey) bool {
+	for _, k := range keys {
+		if k.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// createPath splits a path into keys leniently : malformed paths are accepted, see parsePath for the strict grammar
+// Example : "hits.hits[*]._source.tags[-1]" => hits, hits, *, _source, tags, -1
+// An empty bracket appends to an array, ie "tags[]"
+func createPath(path string) []pathKey {
+	var keys []pathKey
 	infos := strings.Split(path, ".")
 	var tmp strings.Builder
 	for _, k := range infos {
@@ -15,10 +72,12 @@ func createPath(path string) []string {
 			tmp.WriteString(k)
 		}
 		parts := strings.Split(tmp.String(), "[")
-		for _, p := range parts {
+		for i, p := range parts {
 			t := strings.TrimSpace(strings.TrimSuffix(p, "]"))
 			if len(t) > 0 {
-				keys = append(keys, t)
+				keys = append(keys, newPathKey(t, i > 0))
+			} else if i > 0 && strings.HasSuffix(p, "]") {
+				keys = append(keys, pathKey{append: true, bracket: true})
 			}
 		}
 		tmp.Reset()
@@ -26,12 +85,50 @@ func createPath(path string) []string {
 	return keys
 }
 
+// joinPath appends path to base
+func joinPath(base string, path string) string {
+	if len(base) == 0 {
+		return path
+	}
+	if len(path) == 0 {
+		return base
+	}
+	if path[0] == '[' {
+		return base + path
+	}
+	return base + "." + path
+}
+
+// keyPath appends an object key to base
+func keyPath(base string, k string) string {
+	return joinPath(base, EscapePath(k))
+}
+
+// indexPath appends an array index to base
+func indexPath(base string, i int) string {
+	return base + "[" + strconv.Itoa(i) + "]"
+}
+
+// sortedKeys returns the keys of an object in order
+func sortedKeys(o map[string]interface{}) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // EscapePath to escape a path
 // Example
 // - By default  jsonmap.Set("message.raw", "hello world !")
 //   =>  { "message": { "raw": "hello world !" }}
 // - With escape jsonmap.Set(jsonmap.EscapePath("message.raw"), "hello world !")
 //   => { "message.raw": "hello world !" }}
+// A "*" key is escaped to not be a wildcard
 func EscapePath(path string) string {
+	if path == "*" {
+		return "\\*"
+	}
 	return strings.Replace(path, ".", "\\.", -1)
 }
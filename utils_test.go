@@ -16,3 +16,36 @@ func TestEscapePath(t *testing.T) {
 	json.Set(jsonmap.EscapePath("message.raw"), "hello world !")
 	assert.JSONEq(t, `{ "message.raw": "hello world !" }`, json.Stringify())
 }
+
+func TestIndexes(t *testing.T) {
+	j := jsonmap.FromString(`{ "a": [1, 2], "b": { "0": "zero", "00": "double", "+0": "plus", "-0": "minus" } }`)
+	lazy := jsonmap.FromBytesLazy([]byte(j.Stringify()))
+	for path, expected := range map[string]string{
+		"a[0]":  "1",
+		"a.1":   "2",
+		"a[-1]": "2",
+		"a[00]": "1",
+		"a.+0":  "1",
+		"a[-0]": "1",
+		"a[+1]": "2",
+		"b.0":   `"zero"`,
+		"b.00":  `"double"`,
+		"b.+0":  `"plus"`,
+		"b.-0":  `"minus"`,
+	} {
+		assert.Equal(t, expected, j.Get(path).Stringify(), path)
+		assert.Equal(t, expected, lazy.Get(path).Stringify(), path)
+	}
+
+	// canonical indexes only in strict paths
+	for _, path := range []string{"a[00]", "a[-0]", "a[+1]"} {
+		_, err := jsonmap.ParsePath(path)
+		assert.Error(t, err, path)
+	}
+	assert.True(t, jsonmap.MustCompile("b.00").Has(j))
+	assert.Equal(t, int64(2), j.Get("a.01").AsInt())
+	assert.True(t, jsonmap.MustCompile("a.01").Get(j).IsNil())
+	assert.Equal(t, int64(2), jsonmap.MustCompile("a.1").Get(j).AsInt())
+	assert.True(t, j.GetPointer("/a/00").IsNil())
+	assert.Equal(t, `"double"`, j.GetPointer("/b/00").Stringify())
+}